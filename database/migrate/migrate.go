@@ -1,10 +1,12 @@
 package migrate
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/Hello256World/shop-api/database"
 	"github.com/Hello256World/shop-api/models"
+	"gorm.io/gorm"
 )
 
 func Init() {
@@ -23,8 +25,142 @@ func Init() {
 		return
 	}
 
-	err := database.DB.AutoMigrate(&models.Customer{}, &models.Transaction{}, &models.Order{}, &models.Category{}, &models.Product{}, &models.OrderProduct{}, &models.Specification{}, &models.ImageProduct{}, &models.CompareProduct{}, &models.Cart{}, &models.CartProduct{}, &models.Admin{}, &models.SuperAdmin{})
+	// databases created before a status existed need the enum extended
+	for _, status := range models.OrderStatuses {
+		if err := database.DB.Exec(fmt.Sprintf("ALTER TYPE order_status ADD VALUE IF NOT EXISTS '%v'", status)).Error; err != nil {
+			log.Fatalf("Failed to extend order_status enum: %v", err)
+			return
+		}
+	}
+	for _, status := range models.TransactionStatuses {
+		if err := database.DB.Exec(fmt.Sprintf("ALTER TYPE transaction_status ADD VALUE IF NOT EXISTS '%v'", status)).Error; err != nil {
+			log.Fatalf("Failed to extend transaction_status enum: %v", err)
+			return
+		}
+	}
+
+	migrateMoneyColumns()
+
+	err := database.DB.AutoMigrate(&models.Customer{}, &models.Transaction{}, &models.Order{}, &models.Category{}, &models.Product{}, &models.OrderProduct{}, &models.Specification{}, &models.ImageProduct{}, &models.CompareProduct{}, &models.Cart{}, &models.CartProduct{}, &models.Admin{}, &models.SuperAdmin{}, &models.PaymentMismatch{}, &models.OrderStatusHistory{}, &models.StockReservation{}, &models.ReturnRequest{}, &models.ReturnRequestLine{}, &models.ReturnRequestImage{}, &models.Shipment{}, &models.ShipmentItem{}, &models.ShippingMethod{}, &models.ShippingRate{}, &models.Coupon{}, &models.CouponRedemption{}, &models.Promotion{}, &models.PromotionTier{}, &models.WalletAccount{}, &models.WalletEntry{}, &models.GiftCard{}, &models.GiftCardRedemption{}, &models.LoyaltyProgram{}, &models.LoyaltyMultiplier{}, &models.LoyaltyAccount{}, &models.LoyaltyEntry{}, &models.TaxClass{}, &models.OrderTax{}, &models.ProductOption{}, &models.ProductOptionValue{}, &models.ProductVariant{}, &models.SpecificationFilter{}, &models.SpecificationAttribute{}, &models.SpecificationAttributeValue{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	migrateCouponValues()
+	seedShippingMethods()
+	seedTaxClasses()
+
+	if err := models.IndexProducts(database.DB); err != nil {
+		log.Fatalf("Failed to index products for search: %v", err)
+	}
+}
+
+// moneyColumns lists the columns holding utils.Money, which used to be
+// floating point Tomans.
+var moneyColumns = map[string][]string{
+	"product":              {"price"},
+	"compare_product":      {"price"},
+	"order":                {"shipping_cost", "discount_amount", "tax_amount", "total_amount"},
+	"order_product":        {"price", "discount", "tax"},
+	"order_tax":            {"base", "amount"},
+	"transaction":          {"amount", "wallet_amount", "gift_card_amount", "fee"},
+	"payment_mismatch":     {"amount"},
+	"shipping_method":      {"free_shipping_threshold"},
+	"shipping_rate":        {"price"},
+	"coupon":               {"amount", "max_discount", "min_basket"},
+	"coupon_redemption":    {"amount"},
+	"promotion":            {"min_basket"},
+	"promotion_tier":       {"min_amount"},
+	"wallet_account":       {"balance"},
+	"wallet_entry":         {"amount", "balance_after"},
+	"gift_card":            {"initial_balance", "balance"},
+	"gift_card_redemption": {"amount"},
+	"loyalty_program":      {"point_value"},
+}
+
+// migrateMoneyColumns turns the Toman amounts of databases created before
+// utils.Money into whole Rials. Columns that are already integers are left
+// alone, so it is safe to run on every start.
+func migrateMoneyColumns() {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for table, columns := range moneyColumns {
+			for _, column := range columns {
+				var dataType string
+				if err := tx.Raw("SELECT data_type FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?", table, column).
+					Scan(&dataType).Error; err != nil {
+					return err
+				}
+				if dataType != "double precision" && dataType != "real" && dataType != "numeric" {
+					continue
+				}
+				if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %q ALTER COLUMN %q TYPE bigint USING round(%q * 10)`, table, column, column)).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to migrate money columns: %v", err)
+	}
+}
+
+// migrateCouponValues moves the value coupons had, a percentage or an amount
+// in Tomans depending on their type, into their percentage or amount.
+func migrateCouponValues() {
+	if !database.DB.Migrator().HasColumn("coupon", "value") {
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE coupon SET percentage = value WHERE type = ?", models.CouponTypePercentage).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE coupon SET amount = round(value * 10) WHERE type = ?", models.CouponTypeFixed).Error; err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn("coupon", "value")
+	})
 	if err != nil {
+		log.Fatalf("Failed to migrate coupon values: %v", err)
+	}
+}
+
+// seedShippingMethods creates the delivery methods orders were always sent
+// with, free of charge as they were before shipping was priced, so checkout
+// keeps working until the rates are set up.
+func seedShippingMethods() {
+	var count int64
+	if err := database.DB.Model(&models.ShippingMethod{}).Count(&count).Error; err != nil {
+		log.Fatal(err)
+	}
+	if count > 0 {
+		return
+	}
+
+	methods := []models.ShippingMethod{
+		{Code: "post", Name: "پست", Calculator: "weight", Rates: []models.ShippingRate{{Price: 0}}},
+		{Code: "pickup", Name: "تحویل حضوری", Calculator: "pickup"},
+	}
+	if err := database.DB.Create(&methods).Error; err != nil {
+		log.Fatal(err)
+	}
+}
+
+// seedTaxClasses creates the value added tax every product is charged. Prices
+// were always entered with the tax in them, so the class is inclusive and
+// totals stay the same, only the tax is now shown separately.
+func seedTaxClasses() {
+	var count int64
+	if err := database.DB.Model(&models.TaxClass{}).Count(&count).Error; err != nil {
+		log.Fatal(err)
+	}
+	if count > 0 {
+		return
+	}
+
+	inclusive, isDefault := true, true
+	taxClass := models.TaxClass{Name: "مالیات بر ارزش افزوده", Rate: 10, Inclusive: &inclusive, IsDefault: &isDefault}
+	if err := database.DB.Create(&taxClass).Error; err != nil {
 		log.Fatal(err)
 	}
 }
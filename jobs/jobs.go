@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Start runs the background jobs of the shop until the process exits.
+func Start(db *gorm.DB) {
+	every(envMinutes("PAYMENT_RECONCILE_INTERVAL", 10), "payment reconciler", NewPaymentReconciler(db).Run)
+	every(envMinutes("ORDER_EXPIRY_INTERVAL", 5), "order expiry", NewOrderExpirySweeper(db).Run)
+	every(envMinutes("LOYALTY_INTERVAL", 60), "loyalty", NewLoyaltyKeeper(db).Run)
+}
+
+// every calls job once per interval in its own goroutine.
+func every(interval time.Duration, name string, job func() error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := job(); err != nil {
+				log.Printf("%v: %v", name, err)
+			}
+		}
+	}()
+}
+
+// envMinutes reads a number of minutes from the environment.
+func envMinutes(name string, fallback int) time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || minutes <= 0 {
+		minutes = fallback
+	}
+	return time.Duration(minutes) * time.Minute
+}
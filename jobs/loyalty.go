@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Hello256World/shop-api/models"
+	"gorm.io/gorm"
+)
+
+// LoyaltyKeeper grants the birthday bonus of the loyalty program and expires
+// the points that were not spent in time.
+type LoyaltyKeeper struct {
+	loyaltyService *models.LoyaltyService
+}
+
+func NewLoyaltyKeeper(db *gorm.DB) *LoyaltyKeeper {
+	return &LoyaltyKeeper{
+		loyaltyService: models.NewLoyaltyService(db),
+	}
+}
+
+func (k *LoyaltyKeeper) Run() error {
+	now := time.Now()
+	// points expire even when some birthday bonuses could not be granted
+	return errors.Join(k.loyaltyService.GrantBirthdayBonuses(now), k.loyaltyService.ExpirePoints(now))
+}
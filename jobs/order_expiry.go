@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+)
+
+// OrderExpirySweeper fails the orders that are still waiting for payment after
+// the payment window, expires their transactions and releases their stock.
+// Before giving up on an order the gateway is asked once more, so a payment
+// that went through late is verified instead of expired.
+type OrderExpirySweeper struct {
+	orderService           *models.OrderService
+	transactionService     *models.TransactionService
+	paymentService         *models.PaymentService
+	paymentMismatchService *models.PaymentMismatchService
+	paymentWindow          time.Duration
+	restoreCart            bool
+}
+
+func NewOrderExpirySweeper(db *gorm.DB) *OrderExpirySweeper {
+	return &OrderExpirySweeper{
+		orderService:           models.NewOrderService(db),
+		transactionService:     models.NewTransactionService(db),
+		paymentService:         models.NewPaymentService(db),
+		paymentMismatchService: models.NewPaymentMismatchService(db),
+		paymentWindow:          envMinutes("ORDER_PAYMENT_WINDOW", 30),
+		restoreCart:            os.Getenv("ORDER_EXPIRY_RESTORE_CART") == "true",
+	}
+}
+
+func (s *OrderExpirySweeper) Run() error {
+	orders, err := s.orderService.GetWaitingForPayment(time.Now().Add(-s.paymentWindow))
+	if err != nil {
+		return err
+	}
+
+	for i := range *orders {
+		order := &(*orders)[i]
+
+		transaction, err := s.transactionService.GetById(order.TransactionID)
+		if err != nil {
+			log.Printf("order expiry: order %v: %v", order.ID, err)
+			continue
+		}
+
+		if transaction.Authority != nil {
+			if paid := s.checkGateway(order, transaction); paid {
+				continue
+			}
+		}
+
+		// an order paid meanwhile is left as it is
+		if err := s.paymentService.Expire(transaction, "payment was not completed in time", s.restoreCart); err != nil && !errors.Is(err, models.ErrPaymentSettled) {
+			log.Printf("order expiry: order %v: %v", order.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// checkGateway verifies the payment of the order if the gateway says it was
+// paid and reports whether the order should be left alone.
+func (s *OrderExpirySweeper) checkGateway(order *models.Order, transaction *models.Transaction) bool {
+	gateway, err := utils.NewPaymentGateway(transaction.Gateway)
+	if err != nil {
+		return false
+	}
+
+	status, _, err := gateway.Inquiry(*transaction.Authority)
+	if err != nil {
+		return false
+	}
+
+	switch status {
+	case "PAID":
+		if _, _, _, err := s.paymentService.Verify(order, transaction, *transaction.Authority, "OK"); err != nil {
+			log.Printf("order expiry: verify order %v: %v", order.ID, err)
+		}
+		return true
+	case "VERIFIED":
+		reportPaymentMismatch(s.paymentMismatchService, gateway.Name(), transaction.ID, *transaction.Authority, transaction.Amount, models.PaymentMismatchVerifiedAtGateway, "")
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+)
+
+// PaymentReconciler finds payments the shop never heard back about, e.g. a
+// customer who paid but closed the browser before returning from the gateway.
+// Paid payments are verified and anything that does not add up is reported
+// as a PaymentMismatch. Abandoned payments are left to the OrderExpirySweeper.
+type PaymentReconciler struct {
+	transactionService     *models.TransactionService
+	orderService           *models.OrderService
+	paymentService         *models.PaymentService
+	paymentMismatchService *models.PaymentMismatchService
+	verifyAfter            time.Duration
+}
+
+func NewPaymentReconciler(db *gorm.DB) *PaymentReconciler {
+	return &PaymentReconciler{
+		transactionService:     models.NewTransactionService(db),
+		orderService:           models.NewOrderService(db),
+		paymentService:         models.NewPaymentService(db),
+		paymentMismatchService: models.NewPaymentMismatchService(db),
+		verifyAfter:            envMinutes("PAYMENT_VERIFY_AFTER", 5),
+	}
+}
+
+func (r *PaymentReconciler) Run() error {
+	pending, err := r.transactionService.GetPending()
+	if err != nil {
+		return err
+	}
+
+	byGateway := make(map[string][]models.Transaction)
+	for _, transaction := range *pending {
+		byGateway[transaction.Gateway] = append(byGateway[transaction.Gateway], transaction)
+	}
+	if _, ok := byGateway[utils.DefaultPaymentGatewayName()]; !ok {
+		byGateway[utils.DefaultPaymentGatewayName()] = nil
+	}
+
+	for name, transactions := range byGateway {
+		gateway, err := utils.NewPaymentGateway(name)
+		if err != nil {
+			log.Printf("payment reconciler: %v", err)
+			continue
+		}
+		r.reconcile(gateway, transactions)
+	}
+
+	return nil
+}
+
+func (r *PaymentReconciler) reconcile(gateway utils.PaymentGateway, transactions []models.Transaction) {
+	unverified := make(map[string]utils.UnverifiedAuthority)
+	if lister, ok := gateway.(utils.UnverifiedTransactionsLister); ok {
+		authorities, _, err := lister.UnverifiedTransactions()
+		if err != nil {
+			log.Printf("payment reconciler: %v unverified transactions: %v", gateway.Name(), err)
+		}
+		for _, authority := range authorities {
+			unverified[authority.Authority] = authority
+		}
+	}
+
+	for i := range transactions {
+		transaction := &transactions[i]
+		authority := *transaction.Authority
+
+		if _, ok := unverified[authority]; !ok {
+			continue
+		}
+
+		delete(unverified, authority)
+		// give the customer a chance to come back through the callback first
+		if time.Since(transaction.CreatedAt) >= r.verifyAfter {
+			r.verify(gateway, transaction)
+		}
+	}
+
+	// whatever is left was paid to the gateway but is not pending on our side
+	for authority, payment := range unverified {
+		amount := utils.FromGatewayAmount(gateway, payment.Amount)
+		transaction, err := r.transactionService.GetByAuthority(gateway.Name(), authority)
+		if err != nil {
+			r.report(gateway, 0, authority, amount, models.PaymentMismatchUnknownAuthority, "")
+			continue
+		}
+		if transaction.Status == models.TransactionStatusFailed || transaction.Status == models.TransactionStatusExpired {
+			r.report(gateway, transaction.ID, authority, amount, models.PaymentMismatchPaidAfterFailure, fmt.Sprintf("transaction status is %v", transaction.Status))
+		}
+	}
+}
+
+func (r *PaymentReconciler) verify(gateway utils.PaymentGateway, transaction *models.Transaction) {
+	order, err := r.orderService.GetByTransactionId(transaction.ID)
+	if err != nil {
+		log.Printf("payment reconciler: transaction %v: %v", transaction.ID, err)
+		return
+	}
+
+	verified, _, statusCode, err := r.paymentService.Verify(order, transaction, *transaction.Authority, "OK")
+	if errors.Is(err, models.ErrPaymentSettled) {
+		return
+	}
+	if err != nil {
+		log.Printf("payment reconciler: verify transaction %v: %v", transaction.ID, err)
+		return
+	}
+
+	if !verified {
+		description := fmt.Sprintf("gateway status code is %v", statusCode)
+		if transaction.FailureCause != nil {
+			description = *transaction.FailureCause
+		}
+		r.report(gateway, transaction.ID, *transaction.Authority, transaction.Amount, models.PaymentMismatchVerificationFailed, description)
+	}
+}
+
+func (r *PaymentReconciler) report(gateway utils.PaymentGateway, transactionId uint64, authority string, amount utils.Money, reason models.PaymentMismatchReason, description string) {
+	reportPaymentMismatch(r.paymentMismatchService, gateway.Name(), transactionId, authority, amount, reason, description)
+}
+
+// reportPaymentMismatch records a mismatch for a super admin to look into.
+func reportPaymentMismatch(service *models.PaymentMismatchService, gateway string, transactionId uint64, authority string, amount utils.Money, reason models.PaymentMismatchReason, description string) {
+	mismatch := models.PaymentMismatch{
+		Gateway:   gateway,
+		Authority: authority,
+		Reason:    reason,
+		Amount:    amount,
+	}
+	if transactionId > 0 {
+		mismatch.TransactionID = &transactionId
+	}
+	if description != "" {
+		mismatch.Description = &description
+	}
+
+	if err := service.Report(&mismatch); err != nil {
+		log.Printf("report payment mismatch %v: %v", authority, err)
+	}
+}
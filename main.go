@@ -4,6 +4,7 @@ import (
 	"github.com/Hello256World/shop-api/database"
 	"github.com/Hello256World/shop-api/database/migrate"
 	"github.com/Hello256World/shop-api/initializers"
+	"github.com/Hello256World/shop-api/jobs"
 	"github.com/Hello256World/shop-api/routes"
 	"github.com/Hello256World/shop-api/utils"
 	"github.com/gin-gonic/gin"
@@ -19,5 +20,6 @@ func main() {
 	server := gin.Default()
 	utils.Validation()
 	routes.RegisterRouter(server, database.DB)
+	jobs.Start(database.DB)
 	server.Run()
 }
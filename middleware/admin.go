@@ -27,10 +27,17 @@ func AdminAccess(c *gin.Context) {
 		return
 	}
 
-	if role, ok := data["role"].(string); !ok || role != "SuperAdmin" && role != "Admin" {
+	role, ok := data["role"].(string)
+
+	if !ok || role != "SuperAdmin" && role != "Admin" {
 		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "شما دسترسی ندارید"})
 		return
 	}
 
+	c.Set("role", role)
+	if adminId, ok := data["customerId"].(float64); ok {
+		c.Set("adminId", uint64(adminId))
+	}
+
 	c.Next()
 }
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Hello256World/shop-api/database"
+	"github.com/gin-gonic/gin"
+)
+
+// idempotentResponse is kept for a key along with the hash of the request
+// body it was sent for. Status is 0 while the request is still running.
+type idempotentResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+	RequestHash string `json:"request_hash"`
+}
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(data string) (int, error) {
+	r.body.WriteString(data)
+	return r.ResponseWriter.WriteString(data)
+}
+
+// Idempotency makes a request carrying an Idempotency-Key header safe to
+// retry. The first response for a key is kept in redis and replayed for
+// every retry with the same key, instead of running the handler again.
+// Keys are scoped to the customer and the requested path, and can't be
+// reused for a request with a different body.
+func Idempotency(c *gin.Context) {
+	key := c.GetHeader("Idempotency-Key")
+
+	if key == "" {
+		c.Next()
+		return
+	}
+
+	requestBody, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "خطا در خواندن درخواست", "error": err.Error()})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(requestBody))
+	sum := sha256.Sum256(requestBody)
+	requestHash := hex.EncodeToString(sum[:])
+
+	redisKey := fmt.Sprintf("idempotency:%v:%v:%v:%v", c.GetUint64("customerId"), c.Request.Method, c.Request.URL.Path, key)
+	ctx := context.Background()
+
+	if stored, err := database.RDB.Get(ctx, redisKey).Bytes(); err == nil {
+		var response idempotentResponse
+		if err := json.Unmarshal(stored, &response); err == nil && response.RequestHash != "" && response.RequestHash != requestHash {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": "این کلید یکتایی قبلا برای درخواست دیگری استفاده شده است"})
+			return
+		}
+		if err != nil || response.Status == 0 {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"message": "درخواست قبلی با این کلید هنوز در حال انجام است"})
+			return
+		}
+
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(response.Status, response.ContentType, response.Body)
+		c.Abort()
+		return
+	}
+
+	ttl := idempotencyTTL()
+
+	placeholder, _ := json.Marshal(idempotentResponse{RequestHash: requestHash})
+	claimed, err := database.RDB.SetNX(ctx, redisKey, placeholder, ttl).Result()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"message": "خطا در بررسی کلید یکتایی درخواست", "error": err.Error()})
+		return
+	}
+	if !claimed {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"message": "درخواست قبلی با این کلید هنوز در حال انجام است"})
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+	c.Writer = recorder
+
+	c.Next()
+
+	// server errors are not the client's fault, let them retry with the same key
+	if recorder.Status() >= http.StatusInternalServerError {
+		database.RDB.Del(ctx, redisKey)
+		return
+	}
+
+	response, err := json.Marshal(idempotentResponse{
+		Status:      recorder.Status(),
+		ContentType: recorder.Header().Get("Content-Type"),
+		Body:        recorder.body.Bytes(),
+		RequestHash: requestHash,
+	})
+	if err != nil {
+		database.RDB.Del(ctx, redisKey)
+		return
+	}
+
+	// without the response a retry would wait on the placeholder until it expires
+	if err := database.RDB.Set(ctx, redisKey, response, ttl).Err(); err != nil {
+		database.RDB.Del(ctx, redisKey)
+	}
+}
+
+// idempotencyTTL is how long responses are kept, IDEMPOTENCY_TTL is in hours.
+func idempotencyTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_TTL"))
+	if err != nil || hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
@@ -11,6 +11,7 @@ type Address struct {
 	ID           uint64     `gorm:"primaryKey"`
 	CustomerID   uint64     `gorm:"not null"`
 	ReceiverName string     `gorm:"not null"`
+	Province     string     `gorm:"not null;default:''"`
 	Address      string     `gorm:"not null"`
 	Phone        string     `gorm:"not null"`
 	NO           string     `gorm:"not null"`
@@ -10,6 +10,7 @@ import (
 type Cart struct {
 	ID         uint64     `gorm:"primaryKey"`
 	CustomerID uint64     `gorm:"unique;not null"`
+	CouponID   *uint64    `gorm:"null"`
 	IsActive   *bool      `gorm:"default:true"`
 	IsDelete   *bool      `gorm:"default:false"`
 	ModifiedAt *time.Time `gorm:"type:timestamp with time zone"`
@@ -11,6 +11,7 @@ type CartProduct struct {
 	ID         uint64     `gorm:"primaryKey"`
 	CartID     uint64     `gorm:"not null"`
 	ProductID  uint64     `gorm:"not null"`
+	VariantID  *uint64    `gorm:"null;index"`
 	Quantity   int        `gorm:"type:int"`
 	ModifiedAt *time.Time `gorm:"type:timestamp with time zone"`
 	CreatedAt  time.Time  `gorm:"type:timestamp with time zone;default:now()"`
@@ -13,6 +13,7 @@ type Category struct {
 	Name       string `gorm:"not null"`
 	Image      string `gorm:"not null"`
 	ParentID   *uint64
+	TaxClassID *uint64
 	IsActive   *bool `gorm:"default:true"`
 	IsDelete   *bool `gorm:"default:false"`
 	ModifiedAt *time.Time
@@ -0,0 +1,296 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+)
+
+type CheckoutService struct {
+	repo repository.Repository[Order]
+}
+
+func NewCheckoutService(db *gorm.DB) *CheckoutService {
+	return &CheckoutService{
+		repo: repository.NewGenericRepository[Order](db),
+	}
+}
+
+type CheckoutInput struct {
+	CustomerID  uint64
+	Address     *Address
+	Description *string
+	Device      string
+	Gateway     string
+	// ShippingMethod is the code of the delivery method the order is sent with
+	ShippingMethod string
+	// UseWallet pays as much of the order as possible, but no more than
+	// WalletLimit when it is set, from the customer's wallet
+	UseWallet   bool
+	WalletLimit *utils.Money
+	// GiftCardCode pays as much of the order as the card holds, before the
+	// wallet and the gateway
+	GiftCardCode string
+	// LoyaltyPoints is how many of the customer's points to spend as a
+	// discount, as far as the loyalty program allows
+	LoyaltyPoints int
+}
+
+// Checkout turns the customer's cart into an order waiting for payment. The
+// transaction, the order, its lines, the stock reserved for them and the
+// emptied cart are all written in one unit of work, so either all of them
+// happen or none of them do.
+func (c *CheckoutService) Checkout(input CheckoutInput) (*Order, *Transaction, error) {
+	var order Order
+	var transaction Transaction
+
+	err := c.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		cart, lines, productsMap, err := loadCartLines(tx, input.CustomerID, true)
+		if err != nil {
+			return err
+		}
+
+		pricing, err := priceCart(tx, cart, lines)
+		if err != nil {
+			return err
+		}
+		if pricing.CouponError != "" {
+			return errors.New(pricing.CouponError)
+		}
+
+		var loyaltyPoints int
+		if input.LoyaltyPoints > 0 {
+			loyaltyPoints, err = redeemLoyaltyPoints(tx, input.CustomerID, input.LoyaltyPoints, pricing)
+			if err != nil {
+				return err
+			}
+		}
+
+		// the free shipping threshold is checked against what is paid for
+		// the goods, after the points are taken off too
+		shippingMethod, shippingCost, err := quoteShippingTx(tx, input.ShippingMethod, ShippingParcel{
+			Weight:       pricing.Weight,
+			Subtotal:     pricing.Total,
+			Province:     input.Address.Province,
+			FreeShipping: pricing.FreeShipping != nil,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := applyTaxes(tx, pricing); err != nil {
+			return err
+		}
+
+		var orderProducts []OrderProduct
+		for _, line := range pricing.Lines {
+			orderProducts = append(orderProducts, OrderProduct{
+				ProductID:    line.ProductID,
+				VariantID:    line.VariantID,
+				Quantity:     line.Quantity,
+				Price:        line.UnitPrice,
+				Discount:     line.Discount,
+				TaxRate:      line.TaxRate,
+				TaxInclusive: line.TaxInclusive,
+				Tax:          line.Tax,
+			})
+		}
+		totalAmount := pricing.Total + shippingCost
+
+		var giftCard *GiftCard
+		var giftCardAmount utils.Money
+		if input.GiftCardCode != "" {
+			giftCard, err = lockGiftCard(tx, input.GiftCardCode)
+			if err != nil {
+				return err
+			}
+			giftCardAmount = min(giftCard.Balance, totalAmount)
+		}
+
+		var walletAmount utils.Money
+		if input.UseWallet {
+			balance, err := walletBalanceTx(tx, input.CustomerID)
+			if err != nil {
+				return err
+			}
+			walletAmount = min(balance, totalAmount-giftCardAmount)
+			if input.WalletLimit != nil {
+				walletAmount = min(walletAmount, *input.WalletLimit)
+			}
+			walletAmount = max(walletAmount, 0)
+		}
+
+		transaction = Transaction{
+			CustomerID:     input.CustomerID,
+			Type:           "default",
+			Gateway:        input.Gateway,
+			Device:         input.Device,
+			Status:         TransactionStatusNew,
+			Amount:         totalAmount - giftCardAmount - walletAmount,
+			WalletAmount:   walletAmount,
+			GiftCardAmount: giftCardAmount,
+		}
+		if transaction.Amount == 0 {
+			transaction.Gateway = walletGateway
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return errors.New("خطا در ساخت تراکنش")
+		}
+
+		order = Order{
+			AddressID:       input.Address.ID,
+			CustomerID:      input.CustomerID,
+			TransactionID:   transaction.ID,
+			CustomerName:    input.Address.ReceiverName,
+			Phone:           input.Address.Phone,
+			Description:     input.Description,
+			DeliverMethod:   shippingMethod.Code,
+			ShippingCost:    shippingCost,
+			DeliveryAddress: input.Address.Address,
+			Status:          OrderStatusWaitingForIPG,
+			Weight:          pricing.Weight,
+			DiscountAmount:  pricing.Discount,
+			LoyaltyPoints:   loyaltyPoints,
+			TaxAmount:       pricing.Tax,
+			TotalAmount:     totalAmount,
+		}
+		if pricing.coupon != nil {
+			order.CouponID = &pricing.coupon.ID
+		}
+		if err := tx.Create(&order).Error; err != nil {
+			return errors.New("خطا در ساخت سفارش")
+		}
+
+		for i := range orderProducts {
+			orderProducts[i].OrderID = order.ID
+		}
+		if err := tx.Create(&orderProducts).Error; err != nil {
+			return errors.New("خطا در ساخت سفارش محصولات")
+		}
+		order.OrderProducts = orderProducts
+
+		taxes, err := orderTaxes(tx, order.ID, pricing.Lines)
+		if err != nil {
+			return err
+		}
+		if len(taxes) > 0 {
+			if err := tx.Create(&taxes).Error; err != nil {
+				return errors.New("خطا در ثبت مالیات سفارش")
+			}
+		}
+		order.Taxes = taxes
+
+		if err := reserveStock(tx, order.ID, productsMap, orderProducts); err != nil {
+			return err
+		}
+
+		if pricing.coupon != nil {
+			if err := redeemCoupon(tx, pricing.coupon, &order, pricing.Lines); err != nil {
+				return err
+			}
+			if err := tx.Model(cart).Update("coupon_id", nil).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := spendLoyaltyPoints(tx, &order); err != nil {
+			return err
+		}
+
+		if giftCard != nil {
+			if err := redeemGiftCard(tx, giftCard, &order, &transaction); err != nil {
+				return err
+			}
+		}
+
+		if err := payWithWallet(tx, &order, &transaction); err != nil {
+			return err
+		}
+
+		if err := tx.Where("cart_id = ?", cart.ID).Delete(&CartProduct{}).Error; err != nil {
+			return errors.New("خطا در حذف کردن محصولات سبد خرید")
+		}
+
+		// an order paid for entirely with a gift card or the wallet needs no gateway
+		if transaction.Amount == 0 {
+			transaction.Status = TransactionStatusSucceed
+			return settleTx(tx, &order, &transaction, OrderStatusNew, "paid with wallet")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &order, &transaction, nil
+}
+
+// Abort fails an order whose payment could not even be requested, releases
+// its stock and puts its items back into the customer's cart, as if checkout
+// never happened.
+func (c *CheckoutService) Abort(order *Order, transaction *Transaction, cause string) error {
+	return c.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		transaction.Status = TransactionStatusFailed
+		transaction.FailureCause = &cause
+		if err := tx.Save(transaction).Error; err != nil {
+			return err
+		}
+
+		if err := changeOrderStatus(tx, order, OrderStatusFailed, OrderChangedBySystem, &cause); err != nil {
+			return err
+		}
+
+		if err := releaseStockReservations(tx, order.ID); err != nil {
+			return err
+		}
+
+		if err := releaseCouponRedemption(tx, order.ID); err != nil {
+			return err
+		}
+
+		if err := releaseWalletPayment(tx, order); err != nil {
+			return err
+		}
+
+		if err := releaseGiftCardRedemption(tx, order.ID); err != nil {
+			return err
+		}
+
+		if err := releaseLoyaltyRedemption(tx, order); err != nil {
+			return err
+		}
+
+		return restoreCart(tx, order)
+	})
+}
+
+// restoreCart adds the lines of order back to its customer's cart.
+func restoreCart(tx *gorm.DB, order *Order) error {
+	var cart Cart
+	if err := tx.Where("customer_id = ?", order.CustomerID).Preload("CartProducts").First(&cart).Error; err != nil {
+		return err
+	}
+
+	var orderProducts []OrderProduct
+	if err := tx.Where("order_id = ?", order.ID).Find(&orderProducts).Error; err != nil {
+		return err
+	}
+
+	for _, orderProduct := range orderProducts {
+		cartProduct := CartProduct{CartID: cart.ID, ProductID: orderProduct.ProductID, VariantID: orderProduct.VariantID}
+		for _, value := range cart.CartProducts {
+			if value.ProductID == orderProduct.ProductID && SameVariant(value.VariantID, orderProduct.VariantID) {
+				cartProduct = value
+			}
+		}
+		cartProduct.Quantity += orderProduct.Quantity
+		if err := tx.Save(&cartProduct).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
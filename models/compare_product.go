@@ -4,20 +4,21 @@ import (
 	"time"
 
 	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
 	"gorm.io/gorm"
 )
 
 type CompareProduct struct {
-	ID         uint64     `gorm:"primaryKey"`
-	ProductID  uint64     `gorm:"not null"`
-	Name       string     `gorm:"not null"`
-	Link       string     `gorm:"not null"`
-	Price      float64    `gorm:"not null"`
-	Image      string     `gorm:"not null"`
-	IsActive   *bool      `gorm:"default:true"`
-	IsDelete   *bool      `gorm:"default:false"`
-	ModifiedAt *time.Time `gorm:"type:timestamp with time zone"`
-	CreatedAt  time.Time  `gorm:"type:timestamp with time zone;default:now()"`
+	ID         uint64      `gorm:"primaryKey"`
+	ProductID  uint64      `gorm:"not null"`
+	Name       string      `gorm:"not null"`
+	Link       string      `gorm:"not null"`
+	Price      utils.Money `gorm:"not null"`
+	Image      string      `gorm:"not null"`
+	IsActive   *bool       `gorm:"default:true"`
+	IsDelete   *bool       `gorm:"default:false"`
+	ModifiedAt *time.Time  `gorm:"type:timestamp with time zone"`
+	CreatedAt  time.Time   `gorm:"type:timestamp with time zone;default:now()"`
 }
 
 func (CompareProduct) TableName() string {
@@ -0,0 +1,354 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CouponType string
+
+const (
+	CouponTypePercentage CouponType = "percentage"
+	CouponTypeFixed      CouponType = "fixed"
+)
+
+func (t CouponType) IsValid() bool {
+	return t == CouponTypePercentage || t == CouponTypeFixed
+}
+
+type Coupon struct {
+	ID   uint64     `gorm:"primaryKey"`
+	Code string     `gorm:"not null;unique"`
+	Type CouponType `gorm:"not null"`
+	// Percentage is what percentage coupons take off and Amount what fixed
+	// ones do
+	Percentage       float64      `gorm:"not null;default:0"`
+	Amount           utils.Money  `gorm:"not null;default:0"`
+	MaxDiscount      *utils.Money `gorm:"null"`
+	MinBasket        *utils.Money `gorm:"null"`
+	UsageLimit       *int         `gorm:"null"`
+	PerCustomerLimit *int         `gorm:"null"`
+	StartsAt         *time.Time   `gorm:"type:timestamp with time zone"`
+	ExpiresAt        *time.Time   `gorm:"type:timestamp with time zone"`
+	IsActive         *bool        `gorm:"default:true"`
+	ModifiedAt       *time.Time   `gorm:"type:timestamp with time zone"`
+	CreatedAt        time.Time    `gorm:"type:timestamp with time zone;default:now()"`
+
+	// Relations
+	Categories  []Category         `gorm:"many2many:coupon_category"`
+	Products    []Product          `gorm:"many2many:coupon_product"`
+	Redemptions []CouponRedemption `gorm:"foreignKey:CouponID" json:"-"`
+}
+
+func (Coupon) TableName() string {
+	return "coupon"
+}
+
+// CouponRedemption records a coupon used by an order. Redemptions of orders
+// that are never paid are deleted, so they don't count against the limits.
+type CouponRedemption struct {
+	ID         uint64      `gorm:"primaryKey"`
+	CouponID   uint64      `gorm:"not null;index"`
+	CustomerID uint64      `gorm:"not null;index"`
+	OrderID    uint64      `gorm:"not null;unique"`
+	Amount     utils.Money `gorm:"not null"`
+	CreatedAt  time.Time   `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (CouponRedemption) TableName() string {
+	return "coupon_redemption"
+}
+
+type CouponService struct {
+	repo repository.Repository[Coupon]
+}
+
+func NewCouponService(db *gorm.DB) *CouponService {
+	return &CouponService{
+		repo: repository.NewGenericRepository[Coupon](db),
+	}
+}
+
+func (c *CouponService) GetAll(code string, take, skip int) (*[]Coupon, error) {
+	var coupons []Coupon
+	query := c.repo.GetQuery()
+	if code != "" {
+		query = query.Where("code LIKE ?", "%"+strings.ToUpper(code)+"%")
+	}
+	err := query.Order("id desc").Offset(skip).Limit(take).Preload("Categories").Preload("Products").Find(&coupons).Error
+	return &coupons, err
+}
+
+func (c *CouponService) GetById(id uint64) (*Coupon, error) {
+	var coupon Coupon
+	res := c.repo.GetQuery().Preload("Categories").Preload("Products").First(&coupon, id)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("کد تخفیف یافت نشد")
+	}
+	return &coupon, res.Error
+}
+
+func (c *CouponService) GetByCode(code string) (*Coupon, error) {
+	var coupon Coupon
+	res := c.repo.GetQuery().Where("code = ?", strings.ToUpper(strings.TrimSpace(code))).Preload("Categories").Preload("Products").First(&coupon)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("کد تخفیف یافت نشد")
+	}
+	return &coupon, res.Error
+}
+
+// Save creates or updates a coupon along with the categories and products it
+// is limited to.
+func (c *CouponService) Save(coupon *Coupon) error {
+	if !coupon.Type.IsValid() {
+		return errors.New("نوع کد تخفیف نامعتبر است")
+	}
+	if coupon.Type == CouponTypePercentage && (coupon.Percentage <= 0 || coupon.Percentage > 100) {
+		return errors.New("درصد تخفیف باید بین ۰ و ۱۰۰ باشد")
+	}
+	if coupon.Type == CouponTypeFixed && coupon.Amount <= 0 {
+		return errors.New("مبلغ تخفیف را وارد کنید")
+	}
+	coupon.Code = strings.ToUpper(strings.TrimSpace(coupon.Code))
+
+	return c.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(coupon).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(coupon).Association("Categories").Replace(coupon.Categories); err != nil {
+			return err
+		}
+		return tx.Model(coupon).Association("Products").Replace(coupon.Products)
+	})
+}
+
+// Apply validates code against the customer's cart and remembers it on the
+// cart, so it is used at checkout.
+func (c *CouponService) Apply(customerId uint64, code string) (*Coupon, error) {
+	coupon, err := c.GetByCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		cart, lines, _, err := loadCartLines(tx, customerId, false)
+		if err != nil {
+			return err
+		}
+
+		if err := validateCoupon(tx, coupon, customerId); err != nil {
+			return err
+		}
+		if _, err := applyCoupon(tx, coupon, lines); err != nil {
+			return err
+		}
+
+		return tx.Model(cart).Update("coupon_id", coupon.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return coupon, nil
+}
+
+// Remove takes the coupon off the customer's cart.
+func (c *CouponService) Remove(customerId uint64) error {
+	return c.repo.GetQuery().Model(&Cart{}).Where("customer_id = ?", customerId).Update("coupon_id", nil).Error
+}
+
+// validateCoupon checks everything about a coupon that doesn't depend on the
+// basket: whether it is active, in its validity window and within its limits.
+func validateCoupon(tx *gorm.DB, coupon *Coupon, customerId uint64) error {
+	now := time.Now()
+	if coupon.IsActive != nil && !*coupon.IsActive {
+		return errors.New("کد تخفیف غیرفعال است")
+	}
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return errors.New("زمان استفاده از کد تخفیف هنوز نرسیده است")
+	}
+	if coupon.ExpiresAt != nil && now.After(*coupon.ExpiresAt) {
+		return errors.New("کد تخفیف منقضی شده است")
+	}
+
+	if coupon.UsageLimit != nil {
+		var used int64
+		if err := tx.Model(&CouponRedemption{}).Where("coupon_id = ?", coupon.ID).Count(&used).Error; err != nil {
+			return err
+		}
+		if used >= int64(*coupon.UsageLimit) {
+			return errors.New("ظرفیت استفاده از کد تخفیف تکمیل شده است")
+		}
+	}
+	if coupon.PerCustomerLimit != nil {
+		var used int64
+		if err := tx.Model(&CouponRedemption{}).Where("coupon_id = ? AND customer_id = ?", coupon.ID, customerId).Count(&used).Error; err != nil {
+			return err
+		}
+		if used >= int64(*coupon.PerCustomerLimit) {
+			return errors.New("شما قبلا از این کد تخفیف استفاده کرده اید")
+		}
+	}
+
+	return nil
+}
+
+// applyCoupon spreads the coupon's discount over the lines it applies to, in
+// proportion to their price, and returns the total discount.
+func applyCoupon(tx *gorm.DB, coupon *Coupon, lines []PricedLine) (utils.Money, error) {
+	var basket utils.Money
+	for _, line := range lines {
+		basket += line.Total
+	}
+	if coupon.MinBasket != nil && basket < *coupon.MinBasket {
+		return 0, fmt.Errorf("حداقل مبلغ خرید برای این کد تخفیف %v تومان است", *coupon.MinBasket)
+	}
+
+	inScope, err := couponScope(tx, coupon)
+	if err != nil {
+		return 0, err
+	}
+
+	var eligible []int
+	var eligibleTotal utils.Money
+	for i, line := range lines {
+		if inScope(line) {
+			eligible = append(eligible, i)
+			eligibleTotal += line.Total
+		}
+	}
+	if len(eligible) == 0 || eligibleTotal <= 0 {
+		return 0, errors.New("کد تخفیف برای محصولات سبد خرید شما قابل استفاده نیست")
+	}
+
+	discount := coupon.Amount
+	if coupon.Type == CouponTypePercentage {
+		discount = eligibleTotal.Percent(coupon.Percentage)
+	}
+	if coupon.MaxDiscount != nil && discount > *coupon.MaxDiscount {
+		discount = *coupon.MaxDiscount
+	}
+	discount = min(discount, eligibleTotal)
+
+	remaining := discount
+	for n, i := range eligible {
+		share := discount.Share(lines[i].Total, eligibleTotal)
+		if n == len(eligible)-1 || share > remaining {
+			share = remaining
+		}
+		lines[i].addAdjustment(PriceAdjustment{
+			Source:   PriceAdjustmentCoupon,
+			SourceID: coupon.ID,
+			Label:    coupon.Code,
+			Amount:   share,
+		})
+		remaining -= share
+	}
+
+	return discount, nil
+}
+
+// couponScope returns whether a line is covered by the coupon. Coupons
+// without categories or products cover every line.
+func couponScope(tx *gorm.DB, coupon *Coupon) (func(PricedLine) bool, error) {
+	var categoryIds, productIds []uint64
+	for _, category := range coupon.Categories {
+		categoryIds = append(categoryIds, category.ID)
+	}
+	for _, product := range coupon.Products {
+		productIds = append(productIds, product.ID)
+	}
+	return lineScope(tx, categoryIds, productIds)
+}
+
+// lineScope returns whether a line is one of the products or in one of the
+// categories given, a category covering the products of its subcategories
+// too. Every line is in scope when neither is given.
+func lineScope(tx *gorm.DB, categoryIds, productIds []uint64) (func(PricedLine) bool, error) {
+	if len(categoryIds) == 0 && len(productIds) == 0 {
+		return func(PricedLine) bool { return true }, nil
+	}
+
+	products := make(map[uint64]bool, len(productIds))
+	for _, id := range productIds {
+		products[id] = true
+	}
+	categories := make(map[uint64]bool, len(categoryIds))
+	for _, id := range categoryIds {
+		categories[id] = true
+	}
+
+	parents, err := categoryParents(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(line PricedLine) bool {
+		if products[line.ProductID] {
+			return true
+		}
+		// the depth guards against a category that is its own ancestor
+		id := &line.CategoryID
+		for depth := 0; id != nil && depth <= len(parents); depth++ {
+			if categories[*id] {
+				return true
+			}
+			id = parents[*id]
+		}
+		return false
+	}, nil
+}
+
+// categoryParents maps every category to its parent.
+func categoryParents(tx *gorm.DB) (map[uint64]*uint64, error) {
+	var categories []Category
+	if err := tx.Select("id", "parent_id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	parents := make(map[uint64]*uint64, len(categories))
+	for _, category := range categories {
+		parents[category.ID] = category.ParentID
+	}
+	return parents, nil
+}
+
+// redeemCoupon records the coupon used by an order, locking the coupon so
+// two checkouts can't both take its last use. The amount redeemed is what
+// the coupon took off lines, leaving out the order's other discounts.
+func redeemCoupon(tx *gorm.DB, coupon *Coupon, order *Order, lines []PricedLine) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&Coupon{}, coupon.ID).Error; err != nil {
+		return err
+	}
+	if err := validateCoupon(tx, coupon, order.CustomerID); err != nil {
+		return err
+	}
+
+	var amount utils.Money
+	for _, line := range lines {
+		for _, adjustment := range line.Adjustments {
+			if adjustment.Source == PriceAdjustmentCoupon && adjustment.SourceID == coupon.ID {
+				amount += adjustment.Amount
+			}
+		}
+	}
+
+	return tx.Create(&CouponRedemption{
+		CouponID:   coupon.ID,
+		CustomerID: order.CustomerID,
+		OrderID:    order.ID,
+		Amount:     amount,
+	}).Error
+}
+
+// releaseCouponRedemption gives the coupon use of an unpaid order back.
+func releaseCouponRedemption(tx *gorm.DB, orderId uint64) error {
+	return tx.Where("order_id = ?", orderId).Delete(&CouponRedemption{}).Error
+}
@@ -0,0 +1,268 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GiftCard struct {
+	ID             uint64      `gorm:"primaryKey"`
+	Code           string      `gorm:"not null;unique"`
+	InitialBalance utils.Money `gorm:"not null"`
+	Balance        utils.Money `gorm:"not null"`
+	ExpiresAt      *time.Time  `gorm:"type:timestamp with time zone"`
+	IsActive       *bool       `gorm:"default:true"`
+	IssuedBy       string      `gorm:"not null"`
+	// the order the card was bought with, if it was bought
+	PurchaseOrderID   *uint64 `gorm:"null;index"`
+	PurchaseProductID *uint64 `gorm:"null"`
+	// the refund that took the bought card back
+	RevokedByID *uint64    `gorm:"null;index"`
+	ModifiedAt  *time.Time `gorm:"type:timestamp with time zone"`
+	CreatedAt   time.Time  `gorm:"type:timestamp with time zone;default:now()"`
+
+	// Relations
+	Redemptions []GiftCardRedemption `gorm:"foreignKey:GiftCardID"`
+}
+
+func (GiftCard) TableName() string {
+	return "gift_card"
+}
+
+// GiftCardRedemption is an amount taken off a gift card for an order, or
+// given back to it when the order wasn't paid, in which case Amount is negative.
+type GiftCardRedemption struct {
+	ID            uint64      `gorm:"primaryKey"`
+	GiftCardID    uint64      `gorm:"not null;index"`
+	CustomerID    uint64      `gorm:"not null"`
+	OrderID       uint64      `gorm:"not null;index"`
+	TransactionID uint64      `gorm:"not null"`
+	Amount        utils.Money `gorm:"not null"`
+	CreatedAt     time.Time   `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (GiftCardRedemption) TableName() string {
+	return "gift_card_redemption"
+}
+
+type GiftCardService struct {
+	repo repository.Repository[GiftCard]
+}
+
+func NewGiftCardService(db *gorm.DB) *GiftCardService {
+	return &GiftCardService{
+		repo: repository.NewGenericRepository[GiftCard](db),
+	}
+}
+
+func (g *GiftCardService) GetAll(code string, purchasedBy uint64, take, skip int) (*[]GiftCard, error) {
+	var giftCards []GiftCard
+	query := g.repo.GetQuery()
+	if code != "" {
+		query = query.Where("code = ?", normalizeGiftCardCode(code))
+	}
+	if purchasedBy > 0 {
+		query = query.Where(`purchase_order_id IN (SELECT id FROM "order" WHERE customer_id = ?)`, purchasedBy)
+	}
+	err := query.Order("id desc").Offset(skip).Limit(take).Preload("Redemptions").Find(&giftCards).Error
+	return &giftCards, err
+}
+
+func (g *GiftCardService) GetById(id uint64) (*GiftCard, error) {
+	var giftCard GiftCard
+	res := g.repo.GetQuery().Preload("Redemptions").First(&giftCard, id)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("کارت هدیه یافت نشد")
+	}
+	return &giftCard, res.Error
+}
+
+// GetByCode returns a gift card by the code printed on it.
+func (g *GiftCardService) GetByCode(code string) (*GiftCard, error) {
+	var giftCard GiftCard
+	res := g.repo.GetQuery().Where("code = ?", normalizeGiftCardCode(code)).First(&giftCard)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("کارت هدیه یافت نشد")
+	}
+	return &giftCard, res.Error
+}
+
+// Issue creates a gift card with a new random code.
+func (g *GiftCardService) Issue(giftCard *GiftCard) error {
+	if giftCard.InitialBalance <= 0 {
+		return errors.New("مبلغ کارت هدیه باید بیشتر از صفر باشد")
+	}
+	return g.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		return issueGiftCard(tx, giftCard)
+	})
+}
+
+func (g *GiftCardService) Update(giftCard *GiftCard) error {
+	return g.repo.GetQuery().Omit(clause.Associations).Save(giftCard).Error
+}
+
+func normalizeGiftCardCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func issueGiftCard(tx *gorm.DB, giftCard *GiftCard) error {
+	code, err := utils.RandomCode(4, 4)
+	if err != nil {
+		return err
+	}
+
+	giftCard.Code = code
+	giftCard.Balance = giftCard.InitialBalance
+	return tx.Create(giftCard).Error
+}
+
+// lockGiftCard loads a gift card that can be spent, locked until tx ends.
+func lockGiftCard(tx *gorm.DB, code string) (*GiftCard, error) {
+	var giftCard GiftCard
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", normalizeGiftCardCode(code)).First(&giftCard).Error; err != nil {
+		return nil, errors.New("کارت هدیه یافت نشد")
+	}
+	if giftCard.IsActive != nil && !*giftCard.IsActive {
+		return nil, errors.New("کارت هدیه غیرفعال است")
+	}
+	if giftCard.ExpiresAt != nil && time.Now().After(*giftCard.ExpiresAt) {
+		return nil, errors.New("کارت هدیه منقضی شده است")
+	}
+	if giftCard.Balance <= 0 {
+		return nil, errors.New("موجودی کارت هدیه تمام شده است")
+	}
+	return &giftCard, nil
+}
+
+// redeemGiftCard takes the gift card part of an order's payment off the card.
+func redeemGiftCard(tx *gorm.DB, giftCard *GiftCard, order *Order, transaction *Transaction) error {
+	if transaction.GiftCardAmount <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	giftCard.Balance -= transaction.GiftCardAmount
+	giftCard.ModifiedAt = &now
+	if err := tx.Omit(clause.Associations).Save(giftCard).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&GiftCardRedemption{
+		GiftCardID:    giftCard.ID,
+		CustomerID:    order.CustomerID,
+		OrderID:       order.ID,
+		TransactionID: transaction.ID,
+		Amount:        transaction.GiftCardAmount,
+	}).Error
+}
+
+// releaseGiftCardRedemption puts what an unpaid order took off its gift card
+// back on it. It is safe to call more than once.
+func releaseGiftCardRedemption(tx *gorm.DB, orderId uint64) error {
+	var redemptions []GiftCardRedemption
+	if err := tx.Where("order_id = ?", orderId).Find(&redemptions).Error; err != nil {
+		return err
+	}
+
+	used := make(map[uint64]*GiftCardRedemption)
+	for _, redemption := range redemptions {
+		if reversal, ok := used[redemption.GiftCardID]; ok {
+			reversal.Amount -= redemption.Amount
+			continue
+		}
+		used[redemption.GiftCardID] = &GiftCardRedemption{
+			GiftCardID:    redemption.GiftCardID,
+			CustomerID:    redemption.CustomerID,
+			OrderID:       orderId,
+			TransactionID: redemption.TransactionID,
+			Amount:        -redemption.Amount,
+		}
+	}
+
+	for _, reversal := range used {
+		if reversal.Amount >= 0 {
+			continue
+		}
+		if err := tx.Model(&GiftCard{}).Where("id = ?", reversal.GiftCardID).Update("balance", gorm.Expr("balance - ?", reversal.Amount)).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(reversal).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// issuePurchasedGiftCards creates a card for every gift card product a paid
+// order bought, worth what the customer paid for it.
+func issuePurchasedGiftCards(tx *gorm.DB, order *Order) error {
+	var orderProducts []OrderProduct
+	if err := tx.Joins("JOIN product ON product.id = order_product.product_id").
+		Where("order_product.order_id = ? AND product.is_gift_card = ?", order.ID, true).
+		Find(&orderProducts).Error; err != nil {
+		return err
+	}
+
+	for _, orderProduct := range orderProducts {
+		value := orderProduct.refundAmount(1)
+		for i := 0; i < orderProduct.Quantity; i++ {
+			giftCard := GiftCard{
+				InitialBalance:    value,
+				IssuedBy:          OrderChangedBy("Customer", order.CustomerID),
+				PurchaseOrderID:   &order.ID,
+				PurchaseProductID: &orderProduct.ProductID,
+			}
+			if err := issueGiftCard(tx, &giftCard); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// revokePurchasedGiftCards deactivates quantity of the untouched cards an
+// order bought as productId, for refunding them.
+func revokePurchasedGiftCards(tx *gorm.DB, orderId, productId uint64, quantity int, refundId uint64) error {
+	var giftCards []GiftCard
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("purchase_order_id = ? AND purchase_product_id = ? AND revoked_by_id IS NULL", orderId, productId).
+		Order("id").Find(&giftCards).Error; err != nil {
+		return err
+	}
+	if len(giftCards) == 0 {
+		return nil
+	}
+
+	revoked := 0
+	inactive := false
+	for _, giftCard := range giftCards {
+		if revoked == quantity {
+			break
+		}
+		if giftCard.Balance != giftCard.InitialBalance {
+			continue
+		}
+		if err := tx.Model(&giftCard).Updates(map[string]interface{}{"is_active": &inactive, "revoked_by_id": refundId}).Error; err != nil {
+			return err
+		}
+		revoked++
+	}
+
+	if revoked < quantity {
+		return errors.New("کارت هدیه خریداری شده استفاده شده است و قابل بازپرداخت نیست")
+	}
+	return nil
+}
+
+// restoreRevokedGiftCards reactivates the cards a failed refund revoked.
+func restoreRevokedGiftCards(tx *gorm.DB, refundId uint64) error {
+	return tx.Model(&GiftCard{}).Where("revoked_by_id = ?", refundId).Updates(map[string]interface{}{"is_active": true, "revoked_by_id": nil}).Error
+}
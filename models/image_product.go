@@ -12,6 +12,7 @@ type ImageProduct struct {
 	Image      string     `gorm:"not null"`
 	Priority   int        `gorm:"not null"`
 	ProductID  uint64     `gorm:"not null"`
+	VariantID  *uint64    `gorm:"null;index"`
 	IsActive   *bool      `gorm:"default:true"`
 	IsDelete   *bool      `gorm:"default:false"`
 	ModifiedAt *time.Time `gorm:"type:timestamp with time zone"`
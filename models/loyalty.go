@@ -0,0 +1,521 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoyaltyEntryKind string
+
+const (
+	LoyaltyEntryEarn       LoyaltyEntryKind = "earn"
+	LoyaltyEntrySignup     LoyaltyEntryKind = "signup"
+	LoyaltyEntryBirthday   LoyaltyEntryKind = "birthday"
+	LoyaltyEntryAdjustment LoyaltyEntryKind = "adjustment"
+	// points spent as a discount at checkout
+	LoyaltyEntryRedeem LoyaltyEntryKind = "redeem"
+	// points spent on an order that was never paid or was refunded in full
+	LoyaltyEntryRedeemReversal LoyaltyEntryKind = "redeem_reversal"
+	// points earned on an order that was refunded
+	LoyaltyEntryEarnReversal LoyaltyEntryKind = "earn_reversal"
+	LoyaltyEntryExpire       LoyaltyEntryKind = "expire"
+)
+
+// PriceAdjustmentLoyalty is the source of the discount paid with points.
+const PriceAdjustmentLoyalty = "loyalty"
+
+// loyaltyProgramID is the id of the only row of the loyalty_program table.
+const loyaltyProgramID = 1
+
+// LoyaltyProgram holds the rules points are earned and spent by. EarnRate is
+// the number of points a Toman paid earns and PointValue the Toman a point is
+// worth at checkout. Points expire ExpiryDays after they are granted, never
+// when it is zero.
+type LoyaltyProgram struct {
+	ID               uint64      `gorm:"primaryKey"`
+	EarnRate         float64     `gorm:"not null;default:0"`
+	PointValue       utils.Money `gorm:"not null;default:0"`
+	MaxRedeemPercent float64     `gorm:"not null;default:100"`
+	SignupBonus      int         `gorm:"not null;default:0"`
+	BirthdayBonus    int         `gorm:"not null;default:0"`
+	ExpiryDays       int         `gorm:"not null;default:0"`
+	IsActive         *bool       `gorm:"default:false"`
+	ModifiedAt       *time.Time  `gorm:"type:timestamp with time zone"`
+	CreatedAt        time.Time   `gorm:"type:timestamp with time zone;default:now()"`
+
+	// Relations
+	Multipliers []LoyaltyMultiplier `gorm:"foreignKey:ProgramID"`
+}
+
+func (LoyaltyProgram) TableName() string {
+	return "loyalty_program"
+}
+
+func (p *LoyaltyProgram) active() bool {
+	return p.IsActive != nil && *p.IsActive
+}
+
+// LoyaltyMultiplier multiplies the points earned on the products of a
+// category and its subcategories.
+type LoyaltyMultiplier struct {
+	ID         uint64  `gorm:"primaryKey"`
+	ProgramID  uint64  `gorm:"not null"`
+	CategoryID uint64  `gorm:"not null;unique"`
+	Multiplier float64 `gorm:"not null"`
+}
+
+func (LoyaltyMultiplier) TableName() string {
+	return "loyalty_multiplier"
+}
+
+// LoyaltyAccount holds a customer's points. Balance equals the sum of the
+// customer's entries and may go below zero when points that were already
+// spent are taken back for a refund.
+type LoyaltyAccount struct {
+	ID         uint64     `gorm:"primaryKey"`
+	CustomerID uint64     `gorm:"not null;unique"`
+	Balance    int        `gorm:"not null;default:0"`
+	ModifiedAt *time.Time `gorm:"type:timestamp with time zone"`
+	CreatedAt  time.Time  `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (LoyaltyAccount) TableName() string {
+	return "loyalty_account"
+}
+
+// LoyaltyEntry is a change of a customer's points. Entries granting points
+// keep in Remaining how many of them are left to spend or expire, points are
+// always spent from the entry expiring first.
+type LoyaltyEntry struct {
+	ID           uint64           `gorm:"primaryKey"`
+	CustomerID   uint64           `gorm:"not null;index"`
+	Kind         LoyaltyEntryKind `gorm:"not null"`
+	Points       int              `gorm:"not null"`
+	Remaining    int              `gorm:"not null;default:0" json:"-"`
+	BalanceAfter int              `gorm:"not null"`
+	ExpiresAt    *time.Time       `gorm:"type:timestamp with time zone"`
+	OrderID      *uint64          `gorm:"null;index"`
+	Description  *string          `gorm:"null;type:text"`
+	CreatedAt    time.Time        `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (LoyaltyEntry) TableName() string {
+	return "loyalty_entry"
+}
+
+type LoyaltyService struct {
+	repo repository.Repository[LoyaltyAccount]
+}
+
+func NewLoyaltyService(db *gorm.DB) *LoyaltyService {
+	return &LoyaltyService{
+		repo: repository.NewGenericRepository[LoyaltyAccount](db),
+	}
+}
+
+// GetProgram returns the loyalty program, an inactive one if it was never set up.
+func (l *LoyaltyService) GetProgram() (*LoyaltyProgram, error) {
+	return loyaltyProgramTx(l.repo.GetQuery())
+}
+
+// SaveProgram stores the rules of the loyalty program, replacing its
+// category multipliers.
+func (l *LoyaltyService) SaveProgram(program *LoyaltyProgram) error {
+	if program.EarnRate < 0 || program.PointValue < 0 || program.SignupBonus < 0 || program.BirthdayBonus < 0 || program.ExpiryDays < 0 {
+		return errors.New("مقادیر باشگاه مشتریان نمی تواند منفی باشد")
+	}
+	if program.MaxRedeemPercent < 0 || program.MaxRedeemPercent > 100 {
+		return errors.New("درصد قابل پرداخت با امتیاز باید بین ۰ و ۱۰۰ باشد")
+	}
+	for _, multiplier := range program.Multipliers {
+		if multiplier.Multiplier < 0 {
+			return errors.New("ضریب امتیاز نمی تواند منفی باشد")
+		}
+	}
+
+	return l.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		program.ID = loyaltyProgramID
+		program.ModifiedAt = &now
+		if err := tx.Omit(clause.Associations).Save(program).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("program_id = ?", program.ID).Delete(&LoyaltyMultiplier{}).Error; err != nil {
+			return err
+		}
+		for i := range program.Multipliers {
+			program.Multipliers[i].ID = 0
+			program.Multipliers[i].ProgramID = program.ID
+		}
+		if len(program.Multipliers) == 0 {
+			return nil
+		}
+		return tx.Create(&program.Multipliers).Error
+	})
+}
+
+// Balance returns the customer's points.
+func (l *LoyaltyService) Balance(customerId uint64) (int, error) {
+	var account LoyaltyAccount
+	res := l.repo.GetQuery().Where("customer_id = ?", customerId).Limit(1).Find(&account)
+	return account.Balance, res.Error
+}
+
+// GetEntries returns the changes of the customer's points, newest first.
+func (l *LoyaltyService) GetEntries(customerId uint64, take, skip int) (*[]LoyaltyEntry, error) {
+	var entries []LoyaltyEntry
+	err := l.repo.GetQuery().Where("customer_id = ?", customerId).Order("id desc").Offset(skip).Limit(take).Find(&entries).Error
+	return &entries, err
+}
+
+// Adjust grants the customer points, or takes them away when points is
+// negative, on behalf of an admin.
+func (l *LoyaltyService) Adjust(customerId uint64, points int, description string) error {
+	if points == 0 {
+		return errors.New("امتیاز نمی تواند صفر باشد")
+	}
+	return l.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		return postLoyalty(tx, customerId, LoyaltyEntryAdjustment, points, nil, &description)
+	})
+}
+
+// GrantSignupBonus gives a new customer the signup bonus of the program.
+func (l *LoyaltyService) GrantSignupBonus(customerId uint64) error {
+	return l.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		program, err := loyaltyProgramTx(tx)
+		if err != nil || !program.active() || program.SignupBonus <= 0 {
+			return err
+		}
+		return postLoyalty(tx, customerId, LoyaltyEntrySignup, program.SignupBonus, nil, nil)
+	})
+}
+
+// GrantBirthdayBonuses gives the birthday bonus to the customers born on the
+// day of now who did not get it this year yet. A customer whose bonus fails
+// doesn't keep the others from theirs, the failures are returned together.
+func (l *LoyaltyService) GrantBirthdayBonuses(now time.Time) error {
+	program, err := l.GetProgram()
+	if err != nil || !program.active() || program.BirthdayBonus <= 0 {
+		return err
+	}
+
+	var customerIds []uint64
+	if err := l.repo.GetQuery().Model(&Customer{}).
+		Where("EXTRACT(MONTH FROM birthday) = ? AND EXTRACT(DAY FROM birthday) = ?", int(now.Month()), now.Day()).
+		Pluck("id", &customerIds).Error; err != nil {
+		return err
+	}
+
+	startOfYear := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	var errs []error
+	for _, customerId := range customerIds {
+		err := l.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+			if err := lockLoyaltyAccount(tx, customerId, &LoyaltyAccount{}); err != nil {
+				return err
+			}
+			var granted int64
+			if err := tx.Model(&LoyaltyEntry{}).Where("customer_id = ? AND kind = ? AND created_at >= ?", customerId, LoyaltyEntryBirthday, startOfYear).Count(&granted).Error; err != nil {
+				return err
+			}
+			if granted > 0 {
+				return nil
+			}
+			return postLoyalty(tx, customerId, LoyaltyEntryBirthday, program.BirthdayBonus, nil, nil)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("customer %v: %v", customerId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ExpirePoints takes away the points granted before their expiry date that
+// were not spent by now, customer by customer like GrantBirthdayBonuses.
+func (l *LoyaltyService) ExpirePoints(now time.Time) error {
+	var customerIds []uint64
+	if err := l.repo.GetQuery().Model(&LoyaltyEntry{}).
+		Where("remaining > 0 AND expires_at <= ?", now).Distinct().
+		Pluck("customer_id", &customerIds).Error; err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, customerId := range customerIds {
+		err := l.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+			if err := lockLoyaltyAccount(tx, customerId, &LoyaltyAccount{}); err != nil {
+				return err
+			}
+			var expired int
+			if err := tx.Model(&LoyaltyEntry{}).Where("customer_id = ? AND remaining > 0 AND expires_at <= ?", customerId, now).
+				Select("COALESCE(SUM(remaining), 0)").Scan(&expired).Error; err != nil {
+				return err
+			}
+			if expired <= 0 {
+				return nil
+			}
+			// the expired entries expire first, so they are the ones spent
+			return postLoyalty(tx, customerId, LoyaltyEntryExpire, -expired, nil, nil)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("customer %v: %v", customerId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func loyaltyProgramTx(tx *gorm.DB) (*LoyaltyProgram, error) {
+	var program LoyaltyProgram
+	res := tx.Preload("Multipliers").Where("id = ?", loyaltyProgramID).Limit(1).Find(&program)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		isActive := false
+		program = LoyaltyProgram{ID: loyaltyProgramID, MaxRedeemPercent: 100, IsActive: &isActive}
+	}
+	return &program, nil
+}
+
+// lockLoyaltyAccount loads the customer's account into account, creating it
+// if needed, and locks it until tx ends.
+func lockLoyaltyAccount(tx *gorm.DB, customerId uint64, account *LoyaltyAccount) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&LoyaltyAccount{CustomerID: customerId}).Error; err != nil {
+		return err
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("customer_id = ?", customerId).First(account).Error
+}
+
+// postLoyalty changes the customer's points by points inside tx. Granted
+// points expire as the program says, taken points are spent from the entries
+// expiring first. Only taking points back for a refund may leave the
+// customer with a negative balance.
+func postLoyalty(tx *gorm.DB, customerId uint64, kind LoyaltyEntryKind, points int, orderId *uint64, description *string) error {
+	if points == 0 {
+		return nil
+	}
+
+	var account LoyaltyAccount
+	if err := lockLoyaltyAccount(tx, customerId, &account); err != nil {
+		return err
+	}
+	if points < 0 && kind != LoyaltyEntryEarnReversal && account.Balance+points < 0 {
+		return errors.New("امتیاز باشگاه مشتریان کافی نیست")
+	}
+
+	now := time.Now()
+	account.Balance += points
+	account.ModifiedAt = &now
+	if err := tx.Save(&account).Error; err != nil {
+		return err
+	}
+
+	entry := LoyaltyEntry{
+		CustomerID:   customerId,
+		Kind:         kind,
+		Points:       points,
+		BalanceAfter: account.Balance,
+		OrderID:      orderId,
+		Description:  description,
+	}
+
+	if points > 0 {
+		program, err := loyaltyProgramTx(tx)
+		if err != nil {
+			return err
+		}
+		if program.ExpiryDays > 0 {
+			expiresAt := now.AddDate(0, 0, program.ExpiryDays)
+			entry.ExpiresAt = &expiresAt
+		}
+		// points making up for a negative balance are gone already
+		entry.Remaining = min(points, max(account.Balance, 0))
+		return tx.Create(&entry).Error
+	}
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return err
+	}
+
+	var lots []LoyaltyEntry
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("customer_id = ? AND remaining > 0", customerId).
+		Order("expires_at asc nulls last, id asc").Find(&lots).Error; err != nil {
+		return err
+	}
+
+	spend := -points
+	for _, lot := range lots {
+		if spend == 0 {
+			break
+		}
+		used := min(spend, lot.Remaining)
+		if err := tx.Model(&lot).Update("remaining", lot.Remaining-used).Error; err != nil {
+			return err
+		}
+		spend -= used
+	}
+	return nil
+}
+
+// redeemLoyaltyPoints spends up to points of the customer's points on the
+// lines of pricing, no more than the program allows, and spreads their value
+// over the lines as a discount. It returns the points spent.
+func redeemLoyaltyPoints(tx *gorm.DB, customerId uint64, points int, pricing *CartPricing) (int, error) {
+	program, err := loyaltyProgramTx(tx)
+	if err != nil {
+		return 0, err
+	}
+	if !program.active() || program.PointValue <= 0 {
+		return 0, errors.New("باشگاه مشتریان فعال نیست")
+	}
+
+	var account LoyaltyAccount
+	if err := lockLoyaltyAccount(tx, customerId, &account); err != nil {
+		return 0, err
+	}
+	if points > account.Balance {
+		return 0, errors.New("امتیاز باشگاه مشتریان کافی نیست")
+	}
+
+	limit := int(pricing.Total.Percent(program.MaxRedeemPercent) / program.PointValue)
+	points = min(points, limit)
+	if points <= 0 {
+		return 0, nil
+	}
+
+	discount := min(program.PointValue.Times(points), pricing.Total)
+	remaining := discount
+	for i := range pricing.Lines {
+		share := discount.Share(pricing.Lines[i].Total, pricing.Total)
+		if i == len(pricing.Lines)-1 || share > remaining {
+			share = remaining
+		}
+		pricing.Lines[i].addAdjustment(PriceAdjustment{
+			Source: PriceAdjustmentLoyalty,
+			Label:  fmt.Sprintf("%v امتیاز", points),
+			Amount: share,
+		})
+		remaining -= share
+	}
+	pricing.Discount += discount
+	pricing.Total -= discount
+
+	return points, nil
+}
+
+// spendLoyaltyPoints records the points an order was discounted with.
+func spendLoyaltyPoints(tx *gorm.DB, order *Order) error {
+	if order.LoyaltyPoints <= 0 {
+		return nil
+	}
+	return postLoyalty(tx, order.CustomerID, LoyaltyEntryRedeem, -order.LoyaltyPoints, &order.ID, nil)
+}
+
+// releaseLoyaltyRedemption gives the points spent on an order back. It is
+// safe to call more than once.
+func releaseLoyaltyRedemption(tx *gorm.DB, order *Order) error {
+	var spent int
+	if err := tx.Model(&LoyaltyEntry{}).
+		Where("order_id = ? AND kind IN ?", order.ID, []LoyaltyEntryKind{LoyaltyEntryRedeem, LoyaltyEntryRedeemReversal}).
+		Select("COALESCE(-SUM(points), 0)").Scan(&spent).Error; err != nil {
+		return err
+	}
+	return postLoyalty(tx, order.CustomerID, LoyaltyEntryRedeemReversal, max(spent, 0), &order.ID, nil)
+}
+
+// earnLoyaltyPoints grants the points a paid order earns. Every line earns
+// what it cost after discounts, times the multiplier of its product's
+// category or closest parent category that has one. Gift cards earn nothing,
+// the orders paid with them do.
+func earnLoyaltyPoints(tx *gorm.DB, order *Order) error {
+	program, err := loyaltyProgramTx(tx)
+	if err != nil || !program.active() || program.EarnRate <= 0 {
+		return err
+	}
+
+	var orderProducts []OrderProduct
+	if err := tx.Where("order_id = ?", order.ID).Find(&orderProducts).Error; err != nil {
+		return err
+	}
+	var productsId []uint64
+	for _, orderProduct := range orderProducts {
+		productsId = append(productsId, orderProduct.ProductID)
+	}
+	productsMap, err := findProducts(tx, productsId)
+	if err != nil {
+		return err
+	}
+	parents, err := categoryParents(tx)
+	if err != nil {
+		return err
+	}
+	multipliers := make(map[uint64]float64, len(program.Multipliers))
+	for _, multiplier := range program.Multipliers {
+		multipliers[multiplier.CategoryID] = multiplier.Multiplier
+	}
+
+	var total int
+	for _, orderProduct := range orderProducts {
+		product, ok := productsMap[orderProduct.ProductID]
+		if !ok || (product.IsGiftCard != nil && *product.IsGiftCard) {
+			continue
+		}
+
+		multiplier := 1.0
+		// the depth guards against a category that is its own ancestor
+		id := &product.CategoryID
+		for depth := 0; id != nil && depth <= len(parents); depth++ {
+			if value, ok := multipliers[*id]; ok {
+				multiplier = value
+				break
+			}
+			id = parents[*id]
+		}
+
+		points := int(math.Floor(orderProduct.refundAmount(orderProduct.Quantity).Tomans() * program.EarnRate * multiplier))
+		if points <= 0 {
+			continue
+		}
+		if err := tx.Model(&orderProduct).Update("loyalty_points", points).Error; err != nil {
+			return err
+		}
+		total += points
+	}
+
+	return postLoyalty(tx, order.CustomerID, LoyaltyEntryEarn, total, &order.ID, nil)
+}
+
+// reverseLoyaltyPoints takes back the points earned on the refunded items of
+// an order, and gives the points spent on it back once it is refunded in full.
+func reverseLoyaltyPoints(tx *gorm.DB, order *Order, orderProducts []OrderProduct, fullyRefunded bool) error {
+	var owed int
+	for _, orderProduct := range orderProducts {
+		if orderProduct.Quantity > 0 {
+			owed += int(math.Round(float64(orderProduct.LoyaltyPoints*orderProduct.RefundedQuantity) / float64(orderProduct.Quantity)))
+		}
+	}
+
+	var reversed int
+	if err := tx.Model(&LoyaltyEntry{}).Where("order_id = ? AND kind = ?", order.ID, LoyaltyEntryEarnReversal).
+		Select("COALESCE(-SUM(points), 0)").Scan(&reversed).Error; err != nil {
+		return err
+	}
+	if owed > reversed {
+		if err := postLoyalty(tx, order.CustomerID, LoyaltyEntryEarnReversal, reversed-owed, &order.ID, nil); err != nil {
+			return err
+		}
+	}
+
+	if fullyRefunded {
+		return releaseLoyaltyRedemption(tx, order)
+	}
+	return nil
+}
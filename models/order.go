@@ -3,10 +3,13 @@ package models
 import (
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderStatus string
@@ -17,8 +20,38 @@ const (
 	OrderStatusRejected      OrderStatus = "rejected"
 	OrderStatusFailed        OrderStatus = "failed"
 	OrderStatusNew           OrderStatus = "new"
+	OrderStatusShipped       OrderStatus = "shipped"
+	OrderStatusDelivered     OrderStatus = "delivered"
+	OrderStatusCancelled     OrderStatus = "cancelled"
+	OrderStatusRefunded      OrderStatus = "refunded"
 )
 
+// OrderStatuses lists every order status, in the order they are declared
+// in the order_status enum of the database.
+var OrderStatuses = []OrderStatus{
+	OrderStatusConfirmed,
+	OrderStatusWaitingForIPG,
+	OrderStatusRejected,
+	OrderStatusNew,
+	OrderStatusFailed,
+	OrderStatusShipped,
+	OrderStatusDelivered,
+	OrderStatusCancelled,
+	OrderStatusRefunded,
+}
+
+// orderStatusTransitions is the graph of the statuses an order may move to
+// from its current status. Statuses missing from the map are final.
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusWaitingForIPG: {OrderStatusNew, OrderStatusFailed, OrderStatusCancelled},
+	OrderStatusNew:           {OrderStatusConfirmed, OrderStatusRejected, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusConfirmed:     {OrderStatusShipped, OrderStatusRefunded},
+	OrderStatusShipped:       {OrderStatusDelivered, OrderStatusRefunded},
+	OrderStatusDelivered:     {OrderStatusRefunded},
+	OrderStatusRejected:      {OrderStatusRefunded},
+	OrderStatusCancelled:     {OrderStatusRefunded},
+}
+
 func (s *OrderStatus) Scan(value interface{}) error {
 	if value == nil {
 		*s = ""
@@ -42,32 +75,51 @@ func (s OrderStatus) Value() (driver.Value, error) {
 
 func (s OrderStatus) isValid() bool {
 	switch s {
-	case OrderStatusConfirmed, OrderStatusFailed, OrderStatusRejected, OrderStatusNew, OrderStatusWaitingForIPG:
+	case OrderStatusConfirmed, OrderStatusFailed, OrderStatusRejected, OrderStatusNew, OrderStatusWaitingForIPG,
+		OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded:
 		return true
 	default:
 		return false
 	}
 }
 
+// CanTransitionTo reports whether an order in status s may be moved to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, status := range orderStatusTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
 type Order struct {
-	ID              uint64 `gorm:"primaryKey"`
-	CustomerID      uint64 `gorm:"not null"`
-	AddressID       uint64 `gorm:"not null"`
-	TransactionID   uint64 `gorm:"null"`
-	CustomerName    string `gorm:"not null"`
-	Phone           string `gorm:"not null"`
-	Description     *string `gorm:"null;type:text"`
-	Weight          float64 `gorm:"not null"`
-	DeliverMethod   string  `gorm:"not null"`
+	ID              uint64      `gorm:"primaryKey"`
+	CustomerID      uint64      `gorm:"not null"`
+	AddressID       uint64      `gorm:"not null"`
+	TransactionID   uint64      `gorm:"null"`
+	CustomerName    string      `gorm:"not null"`
+	Phone           string      `gorm:"not null"`
+	Description     *string     `gorm:"null;type:text"`
+	Weight          float64     `gorm:"not null"`
+	DeliverMethod   string      `gorm:"not null"`
+	ShippingCost    utils.Money `gorm:"not null;default:0"`
+	DiscountAmount  utils.Money `gorm:"not null;default:0"`
+	CouponID        *uint64     `gorm:"null"`
+	LoyaltyPoints   int         `gorm:"not null;default:0"`
+	TaxAmount       utils.Money `gorm:"not null;default:0"`
 	RejectionReason *string
-	TotalAmount     float64     `gorm:"not null"`
+	TotalAmount     utils.Money `gorm:"not null"`
 	DeliveryAddress string      `gorm:"not null"`
 	Status          OrderStatus `gorm:"type:order_status;not null"`
 	ModifiedAt      *time.Time  `gorm:"type:timestamp with time zone"`
 	CreatedAt       time.Time   `gorm:"type:timestamp with time zone;default:now()"`
 
 	// Relations
-	OrderProducts []OrderProduct `gorm:"foreignKey:OrderID"`
+	OrderProducts []OrderProduct       `gorm:"foreignKey:OrderID"`
+	StatusHistory []OrderStatusHistory `gorm:"foreignKey:OrderID" json:"-"`
+	Shipments     []Shipment           `gorm:"foreignKey:OrderID"`
+	Taxes         []OrderTax           `gorm:"foreignKey:OrderID"`
 }
 
 func (Order) TableName() string {
@@ -90,7 +142,7 @@ func (o *OrderService) GetAll(id, customerId uint64, customerName, sortBy, order
 	query := o.repo.GetQuery()
 
 	if id > 0 {
-		query = query.Where("id = ?", id).Limit(1).Preload("OrderProducts").Find(&orders)
+		query = query.Where("id = ?", id).Limit(1).Preload("OrderProducts").Preload("Shipments.Items").Preload("Taxes").Find(&orders)
 	} else {
 		if customerId > 0 {
 			query = query.Where("customer_id = ?", customerId)
@@ -106,7 +158,7 @@ func (o *OrderService) GetAll(id, customerId uint64, customerName, sortBy, order
 			}
 		}
 
-		query = query.Offset(skip).Limit(take).Preload("OrderProducts").Find(&orders)
+		query = query.Offset(skip).Limit(take).Preload("OrderProducts").Preload("Shipments.Items").Preload("Taxes").Find(&orders)
 	}
 
 	return &orders, query.Error
@@ -116,6 +168,23 @@ func (o *OrderService) GetById(id uint64) (*Order, error) {
 	return o.repo.GetByID(id)
 }
 
+// GetWaitingForPayment returns the orders created before the given time that
+// are still waiting for the customer to pay.
+func (o *OrderService) GetWaitingForPayment(before time.Time) (*[]Order, error) {
+	var orders []Order
+	err := o.repo.GetQuery().Where("status = ? AND created_at < ?", OrderStatusWaitingForIPG, before).Find(&orders).Error
+	return &orders, err
+}
+
+func (o *OrderService) GetByTransactionId(transactionId uint64) (*Order, error) {
+	var order Order
+	res := o.repo.GetQuery().Where("transaction_id = ?", transactionId).First(&order)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("سفارشی برای این تراکنش یافت نشد")
+	}
+	return &order, res.Error
+}
+
 func (o *OrderService) Update(order *Order) error {
 	return o.repo.Update(order)
 }
@@ -125,7 +194,7 @@ func (o *OrderService) GetByCustomerId(id, customerId uint64, start, end, custom
 	query := o.repo.GetQuery().Where("customer_id = ?", customerId)
 
 	if id > 0 {
-		query = query.Where("id = ?", id).Limit(1).Preload("OrderProducts").Find(&orders)
+		query = query.Where("id = ?", id).Limit(1).Preload("OrderProducts").Preload("Shipments.Items").Preload("Taxes").Find(&orders)
 	} else {
 		if customerName != "" {
 			query = query.Where("customer_name LIKE ?", "%"+customerName+"%")
@@ -153,7 +222,7 @@ func (o *OrderService) GetByCustomerId(id, customerId uint64, start, end, custom
 			}
 		}
 
-		query = query.Offset(skip).Limit(take).Preload("OrderProducts").Find(&orders)
+		query = query.Offset(skip).Limit(take).Preload("OrderProducts").Preload("Shipments.Items").Preload("Taxes").Find(&orders)
 	}
 
 	return &orders, query.Error
@@ -163,6 +232,78 @@ func (o *OrderService) Create(entity *Order) error {
 	return o.repo.Create(entity)
 }
 
+// Save stores every field of the order and, when status differs from the
+// current one, moves the order to it as ChangeStatus does.
+func (o *OrderService) Save(order *Order, status OrderStatus, changedBy string, reason *string) error {
+	return o.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := lockOrderStatus(tx, order); err != nil {
+			return err
+		}
+		if order.Status == status {
+			return tx.Save(order).Error
+		}
+		return o.ChangeStatusTx(tx, order, status, changedBy, reason)
+	})
+}
+
+// ChangeStatus moves the order to status if the transition is allowed and
+// records who changed it and why in the order's status history.
+func (o *OrderService) ChangeStatus(order *Order, status OrderStatus, changedBy string, reason *string) error {
+	return o.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		return o.ChangeStatusTx(tx, order, status, changedBy, reason)
+	})
+}
+
+// ChangeStatusTx is ChangeStatus running inside the database transaction tx.
+func (o *OrderService) ChangeStatusTx(tx *gorm.DB, order *Order, status OrderStatus, changedBy string, reason *string) error {
+	return changeOrderStatus(tx, order, status, changedBy, reason)
+}
+
+func changeOrderStatus(tx *gorm.DB, order *Order, status OrderStatus, changedBy string, reason *string) error {
+	if err := lockOrderStatus(tx, order); err != nil {
+		return err
+	}
+	if !order.Status.CanTransitionTo(status) {
+		return fmt.Errorf("تغییر وضعیت سفارش از %v به %v مجاز نیست", order.Status, status)
+	}
+
+	history := OrderStatusHistory{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   status,
+		ChangedBy:  changedBy,
+		Reason:     reason,
+	}
+
+	now := time.Now()
+	order.Status = status
+	order.ModifiedAt = &now
+
+	if err := tx.Save(order).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&history).Error
+}
+
+// lockOrderStatus locks the order's row until tx ends and sets its Status to
+// the one in the database, which another request may have changed since the
+// order was loaded.
+func lockOrderStatus(tx *gorm.DB, order *Order) error {
+	var current Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "status").First(&current, order.ID).Error; err != nil {
+		return err
+	}
+	order.Status = current.Status
+	return nil
+}
+
+func (o *OrderService) GetStatusHistory(orderId uint64) (*[]OrderStatusHistory, error) {
+	var history []OrderStatusHistory
+	err := o.repo.GetQuery().Model(&OrderStatusHistory{}).Where("order_id = ?", orderId).Order("created_at asc, id asc").Find(&history).Error
+	return &history, err
+}
+
 func (o *OrderService) BeginTransaction() *gorm.DB {
 	return o.repo.GetQuery().Begin()
 }
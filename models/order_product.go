@@ -4,17 +4,25 @@ import (
 	"time"
 
 	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
 	"gorm.io/gorm"
 )
 
 type OrderProduct struct {
-	ID         uint64     `gorm:"primaryKey"`
-	OrderID    uint64     `gorm:"not null"`
-	ProductID  uint64     `gorm:"not null"`
-	Quantity   int        `gorm:"not null"`
-	Price      float64    `gorm:"not null"`
-	ModifiedAt *time.Time `gorm:"type:timestamp with time zone"`
-	CreatedAt  time.Time  `gorm:"type:timestamp with time zone;default:now()"`
+	ID               uint64      `gorm:"primaryKey"`
+	OrderID          uint64      `gorm:"not null"`
+	ProductID        uint64      `gorm:"not null"`
+	VariantID        *uint64     `gorm:"null"`
+	Quantity         int         `gorm:"not null"`
+	Price            utils.Money `gorm:"not null"`
+	Discount         utils.Money `gorm:"not null;default:0"`
+	RefundedQuantity int         `gorm:"not null;default:0"`
+	LoyaltyPoints    int         `gorm:"not null;default:0"`
+	TaxRate          float64     `gorm:"not null;default:0"`
+	TaxInclusive     bool        `gorm:"not null;default:false"`
+	Tax              utils.Money `gorm:"not null;default:0"`
+	ModifiedAt       *time.Time  `gorm:"type:timestamp with time zone"`
+	CreatedAt        time.Time   `gorm:"type:timestamp with time zone;default:now()"`
 }
 
 func (OrderProduct) TableName() string {
@@ -33,4 +41,17 @@ func NewOrderProductService(db *gorm.DB) *OrderProductService{
 
 func (o *OrderProductService) CreateRange(orderProducts ...OrderProduct) error {
 	return o.repo.GetQuery().Create(orderProducts).Error
-}
\ No newline at end of file
+}
+
+// refundAmount is what quantity items of the line cost the customer, their
+// share of the line's discount taken off and of its tax added.
+func (o *OrderProduct) refundAmount(quantity int) utils.Money {
+	if o.Quantity == 0 {
+		return 0
+	}
+	total := o.Price.Times(o.Quantity) - o.Discount
+	if !o.TaxInclusive {
+		total += o.Tax
+	}
+	return total.Share(utils.Money(quantity), utils.Money(o.Quantity))
+}
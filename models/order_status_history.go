@@ -0,0 +1,30 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// OrderChangedBySystem is recorded as the author of status changes that were
+// not made by a person, e.g. payment verification or background jobs.
+const OrderChangedBySystem = "system"
+
+type OrderStatusHistory struct {
+	ID         uint64      `gorm:"primaryKey"`
+	OrderID    uint64      `gorm:"not null;index"`
+	FromStatus OrderStatus `gorm:"type:order_status;not null"`
+	ToStatus   OrderStatus `gorm:"type:order_status;not null"`
+	ChangedBy  string      `gorm:"not null"`
+	Reason     *string     `gorm:"null;type:text"`
+	CreatedAt  time.Time   `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
+
+// OrderChangedBy formats the author of a status change from the role and id
+// stored on the request by the access middlewares, e.g. "Admin:3".
+func OrderChangedBy(role string, id uint64) string {
+	return fmt.Sprintf("%v:%v", role, id)
+}
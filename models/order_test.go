@@ -0,0 +1,42 @@
+package models
+
+import "testing"
+
+// TestOrderStatusCanTransitionTo walks every pair of statuses, so a status
+// added to the enum without a place in the graph is noticed.
+func TestOrderStatusCanTransitionTo(t *testing.T) {
+	allowed := map[OrderStatus]map[OrderStatus]bool{
+		OrderStatusWaitingForIPG: {OrderStatusNew: true, OrderStatusFailed: true, OrderStatusCancelled: true},
+		OrderStatusNew:           {OrderStatusConfirmed: true, OrderStatusRejected: true, OrderStatusCancelled: true, OrderStatusRefunded: true},
+		OrderStatusConfirmed:     {OrderStatusShipped: true, OrderStatusRefunded: true},
+		OrderStatusShipped:       {OrderStatusDelivered: true, OrderStatusRefunded: true},
+		OrderStatusDelivered:     {OrderStatusRefunded: true},
+		OrderStatusRejected:      {OrderStatusRefunded: true},
+		OrderStatusCancelled:     {OrderStatusRefunded: true},
+	}
+
+	for _, from := range OrderStatuses {
+		for _, to := range OrderStatuses {
+			if got := from.CanTransitionTo(to); got != allowed[from][to] {
+				t.Errorf("%v.CanTransitionTo(%v) = %v, want %v", from, to, got, allowed[from][to])
+			}
+		}
+	}
+
+	if OrderStatus("unknown").CanTransitionTo(OrderStatusNew) {
+		t.Error("an unknown status can move to new")
+	}
+}
+
+func TestOrderStatusTransitionsAreValid(t *testing.T) {
+	for from, targets := range orderStatusTransitions {
+		if !from.isValid() {
+			t.Errorf("transitions from unknown status %v", from)
+		}
+		for _, to := range targets {
+			if !to.isValid() || to == from {
+				t.Errorf("%v moves to %v", from, to)
+			}
+		}
+	}
+}
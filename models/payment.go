@@ -0,0 +1,208 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPaymentSettled is returned when the payment of an order was already
+// settled, by the gateway callback, the reconciler or the expiry sweeper,
+// while it was being settled again.
+var ErrPaymentSettled = errors.New("وضعیت این پرداخت از قبل مشخص شده است")
+
+type PaymentService struct {
+	orderService       *OrderService
+	transactionService *TransactionService
+}
+
+func NewPaymentService(db *gorm.DB) *PaymentService {
+	return &PaymentService{
+		orderService:       NewOrderService(db),
+		transactionService: NewTransactionService(db),
+	}
+}
+
+// Request asks the transaction's gateway for a payment URL the customer can
+// pay the order at, and remembers the authority the gateway handed out.
+func (p *PaymentService) Request(order *Order, transaction *Transaction) (paymentURL string, statusCode int, err error) {
+	gateway, err := utils.NewPaymentGateway(transaction.Gateway)
+	if err != nil {
+		return
+	}
+
+	amount := utils.ToGatewayAmount(gateway, transaction.Amount)
+	callbackURL := utils.PublicURL(fmt.Sprintf("/v1/public/orders/%v", order.ID))
+	description := fmt.Sprintf("پرداخت سفارش شماره %v", order.ID)
+
+	paymentURL, authority, statusCode, err := gateway.NewPaymentRequest(amount, callbackURL, description, "", order.Phone)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	transaction.Authority = &authority
+	transaction.Status = TransactionStatusInProgress
+	transaction.ModifiedAt = &now
+	err = p.transactionService.Update(transaction)
+	return
+}
+
+// Verify asks the transaction's gateway whether the payment went through and
+// stores the outcome on both the order and its transaction. A payment is only
+// accepted when the gateway reports exactly the amount stored on the transaction.
+//
+// Gateway errors other than a failed payment leave the order untouched, so the
+// payment can be verified again later. A payment the gateway has not handed an
+// authority out for yet can't be verified, so a made up callback can't fail it.
+func (p *PaymentService) Verify(order *Order, transaction *Transaction, authority, status string) (verified bool, refID string, statusCode int, err error) {
+	if transaction.Authority == nil || *transaction.Authority != authority {
+		err = errors.New("شناسه پرداخت با تراکنش سفارش مطابقت ندارد")
+		return
+	}
+
+	failureCause := fmt.Sprintf("payment status is %v", status)
+
+	if status == "OK" {
+		gateway, gatewayErr := utils.NewPaymentGateway(transaction.Gateway)
+		if gatewayErr != nil {
+			err = gatewayErr
+			return
+		}
+
+		amount := utils.ToGatewayAmount(gateway, transaction.Amount)
+		result, verifyErr := gateway.PaymentVerification(amount, authority)
+		statusCode = result.StatusCode
+		if result.Raw != "" {
+			transaction.GatewayResponse = &result.Raw
+		}
+
+		switch {
+		case verifyErr != nil && statusCode != -50 && statusCode != -51:
+			err = verifyErr
+			return
+		case statusCode == -50:
+			failureCause = fmt.Sprintf("gateway rejected amount %v", amount)
+		case result.Verified && result.Amount != nil && *result.Amount != amount:
+			failureCause = fmt.Sprintf("gateway reported amount %v, expected %v", *result.Amount, amount)
+		case result.Verified && transaction.Amount+transaction.WalletAmount+transaction.GiftCardAmount != order.TotalAmount:
+			failureCause = fmt.Sprintf("transaction amount %v does not match order total %v", transaction.Amount+transaction.WalletAmount+transaction.GiftCardAmount, order.TotalAmount)
+		case result.Verified:
+			verified = true
+			refID = result.RefID
+			fee := utils.FromGatewayAmount(gateway, result.Fee)
+			transaction.Fee = &fee
+			if result.CardPan != "" {
+				transaction.CardPan = &result.CardPan
+			}
+			if result.CardHash != "" {
+				transaction.CardHash = &result.CardHash
+			}
+		default:
+			failureCause = fmt.Sprintf("gateway status code is %v", statusCode)
+		}
+	}
+
+	now := time.Now()
+	transaction.ModifiedAt = &now
+	if verified {
+		transaction.Status = TransactionStatusSucceed
+		transaction.RetrievalReferenceNumber = &refID
+		err = p.settle(order, transaction, OrderStatusNew, "payment verified")
+	} else {
+		transaction.Status = TransactionStatusFailed
+		transaction.FailureCause = &failureCause
+		err = p.settle(order, transaction, OrderStatusFailed, failureCause)
+	}
+	if err != nil {
+		verified = false
+	}
+	return
+}
+
+// Expire gives up on a payment the customer never finished. The transaction
+// is marked expired, its order failed and the reserved stock released. When
+// restoreItems is set the order's items are put back into the customer's
+// cart, so they can simply try again.
+func (p *PaymentService) Expire(transaction *Transaction, cause string, restoreItems bool) error {
+	order, err := p.orderService.GetByTransactionId(transaction.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	transaction.Status = TransactionStatusExpired
+	transaction.FailureCause = &cause
+	transaction.ModifiedAt = &now
+
+	return p.transactionService.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := settleTx(tx, order, transaction, OrderStatusFailed, cause); err != nil {
+			return err
+		}
+		if restoreItems {
+			return restoreCart(tx, order)
+		}
+		return nil
+	})
+}
+
+// settle stores the outcome of a payment in one database transaction.
+func (p *PaymentService) settle(order *Order, transaction *Transaction, status OrderStatus, reason string) error {
+	return p.transactionService.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		return settleTx(tx, order, transaction, status, reason)
+	})
+}
+
+// settleTx stores the transaction, moves the order to status and decides the
+// fate of its reserved stock, coupon, wallet, gift card and loyalty point
+// payments, which are kept for a paid order and released otherwise. A paid
+// order also gets the gift cards it bought and earns loyalty points.
+//
+// The transaction is locked and read again first, as changeOrderStatus does
+// with the order, and nothing is done unless the payment is still open, so
+// two of the callback, the reconciler and the expiry sweeper can't both
+// settle the same payment.
+func settleTx(tx *gorm.DB, order *Order, transaction *Transaction, status OrderStatus, reason string) error {
+	var current Transaction
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "status").First(&current, transaction.ID).Error; err != nil {
+		return err
+	}
+	if current.Status != TransactionStatusNew && current.Status != TransactionStatusInProgress {
+		return ErrPaymentSettled
+	}
+
+	if err := tx.Save(transaction).Error; err != nil {
+		return err
+	}
+
+	if err := changeOrderStatus(tx, order, status, OrderChangedBySystem, &reason); err != nil {
+		return err
+	}
+
+	if status == OrderStatusNew {
+		if err := issuePurchasedGiftCards(tx, order); err != nil {
+			return err
+		}
+		if err := earnLoyaltyPoints(tx, order); err != nil {
+			return err
+		}
+		return commitStockReservations(tx, order.ID)
+	}
+	if err := releaseCouponRedemption(tx, order.ID); err != nil {
+		return err
+	}
+	if err := releaseWalletPayment(tx, order); err != nil {
+		return err
+	}
+	if err := releaseGiftCardRedemption(tx, order.ID); err != nil {
+		return err
+	}
+	if err := releaseLoyaltyRedemption(tx, order); err != nil {
+		return err
+	}
+	return releaseStockReservations(tx, order.ID)
+}
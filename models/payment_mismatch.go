@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+)
+
+type PaymentMismatchReason string
+
+const (
+	// the gateway holds a paid authority no transaction knows about
+	PaymentMismatchUnknownAuthority PaymentMismatchReason = "unknown_authority"
+	// the gateway holds a paid authority whose transaction already failed or expired
+	PaymentMismatchPaidAfterFailure PaymentMismatchReason = "paid_after_failure"
+	// the payment could not be verified, e.g. the amounts did not match
+	PaymentMismatchVerificationFailed PaymentMismatchReason = "verification_failed"
+	// the gateway says the payment is verified but the transaction is still pending
+	PaymentMismatchVerifiedAtGateway PaymentMismatchReason = "verified_at_gateway"
+)
+
+type PaymentMismatch struct {
+	ID            uint64                `gorm:"primaryKey"`
+	TransactionID *uint64               `gorm:"null"`
+	Gateway       string                `gorm:"not null;uniqueIndex:idx_payment_mismatch"`
+	Authority     string                `gorm:"not null;uniqueIndex:idx_payment_mismatch"`
+	Reason        PaymentMismatchReason `gorm:"not null;uniqueIndex:idx_payment_mismatch"`
+	Amount        utils.Money           `gorm:"not null"`
+	Description   *string               `gorm:"null;type:text"`
+	IsResolved    *bool                 `gorm:"default:false"`
+	ModifiedAt    *time.Time            `gorm:"type:timestamp with time zone"`
+	CreatedAt     time.Time             `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (PaymentMismatch) TableName() string {
+	return "payment_mismatch"
+}
+
+type PaymentMismatchService struct {
+	repo repository.Repository[PaymentMismatch]
+}
+
+func NewPaymentMismatchService(db *gorm.DB) *PaymentMismatchService {
+	return &PaymentMismatchService{
+		repo: repository.NewGenericRepository[PaymentMismatch](db),
+	}
+}
+
+// Report records a mismatch once, reporting the same authority for the
+// same reason again is a no-op.
+func (p *PaymentMismatchService) Report(entity *PaymentMismatch) error {
+	return p.repo.GetQuery().
+		Where("gateway = ? AND authority = ? AND reason = ?", entity.Gateway, entity.Authority, entity.Reason).
+		FirstOrCreate(entity).Error
+}
+
+func (p *PaymentMismatchService) GetAll(isResolved *bool, take, skip int) (*[]PaymentMismatch, error) {
+	var mismatches []PaymentMismatch
+	query := p.repo.GetQuery()
+
+	if isResolved != nil {
+		query = query.Where("is_resolved = ?", *isResolved)
+	}
+
+	err := query.Order("created_at desc").Offset(skip).Limit(take).Find(&mismatches).Error
+	return &mismatches, err
+}
+
+func (p *PaymentMismatchService) GetById(id uint64) (*PaymentMismatch, error) {
+	return p.repo.GetByID(id)
+}
+
+func (p *PaymentMismatchService) Update(entity *PaymentMismatch) error {
+	return p.repo.Update(entity)
+}
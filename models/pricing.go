@@ -0,0 +1,243 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+)
+
+// PricedLine is a cart line with the product's current price, the discounts
+// given on it and its tax. Amounts are for the whole line, not a single item,
+// and Total includes the tax when it is not included in the price.
+type PricedLine struct {
+	CartProductID uint64
+	ProductID     uint64
+	VariantID     *uint64
+	CategoryID    uint64
+	Name          string
+	Quantity      int
+	UnitPrice     utils.Money
+	Weight        float64
+	Subtotal      utils.Money
+	Discount      utils.Money
+	Total         utils.Money
+	Adjustments   []PriceAdjustment
+	TaxClassID    *uint64
+	TaxRate       float64
+	TaxInclusive  bool
+	Tax           utils.Money
+}
+
+// PriceAdjustment is a discount along with the coupon or promotion rule
+// that gave it.
+type PriceAdjustment struct {
+	Source   string
+	SourceID uint64
+	Label    string
+	Amount   utils.Money
+}
+
+const (
+	PriceAdjustmentCoupon    = "coupon"
+	PriceAdjustmentPromotion = "promotion"
+)
+
+func (l *PricedLine) addAdjustment(adjustment PriceAdjustment) {
+	if adjustment.Amount <= 0 {
+		return
+	}
+	l.Adjustments = append(l.Adjustments, adjustment)
+	l.Discount += adjustment.Amount
+	l.Total = l.Subtotal - l.Discount
+}
+
+// CartPricing is what a cart costs before shipping.
+type CartPricing struct {
+	Lines      []PricedLine
+	Subtotal   utils.Money
+	Discount   utils.Money
+	Tax        utils.Money
+	Total      utils.Money
+	Weight     float64
+	CouponCode string `json:",omitempty"`
+	// CouponError tells why the cart's coupon could not be applied
+	CouponError string `json:",omitempty"`
+	// FreeShipping is the promotion that makes shipping free, if any
+	FreeShipping *PriceAdjustment `json:",omitempty"`
+
+	coupon *Coupon
+}
+
+// CartLineError is why a cart product can't be bought anymore, such as its
+// product having been deactivated, so the customer can remove it.
+type CartLineError struct {
+	CartProductID uint64
+	ProductID     uint64
+	Error         string
+}
+
+// loadCartLines loads the customer's cart and the products in it along with
+// their variants, locking them when lock is set, and turns every cart product
+// into a line priced by its variant, if it has one. A cart product that can't
+// be bought fails it.
+func loadCartLines(tx *gorm.DB, customerId uint64, lock bool) (*Cart, []PricedLine, map[uint64]*Product, error) {
+	cart, lines, invalid, productsMap, err := collectCartLines(tx, customerId, lock)
+	if err == nil && len(invalid) > 0 {
+		err = errors.New(invalid[0].Error)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cart, lines, productsMap, nil
+}
+
+// collectCartLines is loadCartLines returning the cart products that can't be
+// bought along with the lines of the others.
+func collectCartLines(tx *gorm.DB, customerId uint64, lock bool) (*Cart, []PricedLine, []CartLineError, map[uint64]*Product, error) {
+	var cart Cart
+	if err := tx.Where("customer_id = ?", customerId).Preload("CartProducts").First(&cart).Error; err != nil || len(cart.CartProducts) == 0 {
+		return nil, nil, nil, nil, errors.New("سبد خرید شما خالی می باشد")
+	}
+
+	var productsId []uint64
+	for _, cartProduct := range cart.CartProducts {
+		productsId = append(productsId, cartProduct.ProductID)
+	}
+
+	var productsMap map[uint64]*Product
+	var err error
+	if lock {
+		productsMap, err = lockProducts(tx, productsId)
+	} else {
+		productsMap, err = findProducts(tx, productsId)
+	}
+	if err == nil {
+		err = loadVariants(tx, productsMap, lock)
+	}
+	if err != nil {
+		return nil, nil, nil, nil, errors.New("خطا در دریافت محصولات سبد خرید")
+	}
+
+	lines := make([]PricedLine, 0, len(cart.CartProducts))
+	var invalid []CartLineError
+	for _, cartProduct := range cart.CartProducts {
+		lineError := CartLineError{CartProductID: cartProduct.ID, ProductID: cartProduct.ProductID}
+
+		product, ok := productsMap[cartProduct.ProductID]
+		if !ok || !*product.IsActive || *product.IsDelete {
+			lineError.Error = fmt.Sprintf("محصول %v نامعتبر است", cartProduct.ProductID)
+			invalid = append(invalid, lineError)
+			continue
+		}
+
+		name, price, weight := product.Name, product.Price, product.ShipmentWeight
+		if cartProduct.VariantID != nil {
+			variant := product.variant(*cartProduct.VariantID)
+			if variant == nil || !variant.Available() {
+				lineError.Error = fmt.Sprintf("تنوع انتخاب شده برای محصول %v نامعتبر است", product.Name)
+				invalid = append(invalid, lineError)
+				continue
+			}
+			name = product.Name + " - " + variant.Title
+			price, weight = variant.price(product), variant.weight(product)
+		} else if len(product.Variants) > 0 {
+			lineError.Error = fmt.Sprintf("برای محصول %v یکی از تنوع ها را انتخاب کنید", product.Name)
+			invalid = append(invalid, lineError)
+			continue
+		}
+
+		subtotal := price.Times(cartProduct.Quantity)
+		lines = append(lines, PricedLine{
+			CartProductID: cartProduct.ID,
+			ProductID:     product.ID,
+			VariantID:     cartProduct.VariantID,
+			CategoryID:    product.CategoryID,
+			Name:          name,
+			Quantity:      cartProduct.Quantity,
+			UnitPrice:     price,
+			Weight:        weight * float64(cartProduct.Quantity),
+			Subtotal:      subtotal,
+			Total:         subtotal,
+		})
+	}
+
+	return &cart, lines, invalid, productsMap, nil
+}
+
+func findProducts(tx *gorm.DB, ids []uint64) (map[uint64]*Product, error) {
+	var products []Product
+	if err := tx.Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	productsMap := make(map[uint64]*Product, len(products))
+	for i := range products {
+		productsMap[products[i].ID] = &products[i]
+	}
+	return productsMap, nil
+}
+
+// priceCart applies the running promotions and then the cart's coupon to
+// lines and adds them up. A coupon that can't be used is left out and the
+// reason reported in CouponError.
+func priceCart(tx *gorm.DB, cart *Cart, lines []PricedLine) (*CartPricing, error) {
+	pricing := CartPricing{Lines: lines}
+
+	if err := applyPromotions(tx, &pricing, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if cart.CouponID != nil {
+		var coupon Coupon
+		err := tx.Preload("Categories").Preload("Products").First(&coupon, *cart.CouponID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		pricing.CouponCode = coupon.Code
+
+		if err != nil {
+			pricing.CouponError = "کد تخفیف یافت نشد"
+		} else if err := validateCoupon(tx, &coupon, cart.CustomerID); err != nil {
+			pricing.CouponError = err.Error()
+		} else if _, err := applyCoupon(tx, &coupon, pricing.Lines); err != nil {
+			pricing.CouponError = err.Error()
+		} else {
+			pricing.coupon = &coupon
+		}
+	}
+
+	for _, line := range pricing.Lines {
+		pricing.Subtotal += line.Subtotal
+		pricing.Discount += line.Discount
+		pricing.Total += line.Total
+		pricing.Weight += line.Weight
+	}
+
+	return &pricing, nil
+}
+
+// Price returns what the customer's cart costs. A cart with products that
+// can't be bought anymore is not priced, the reasons are returned instead
+// so the customer can remove them.
+func (c *CartService) Price(customerId uint64) (*CartPricing, []CartLineError, error) {
+	tx := c.repo.GetQuery()
+
+	cart, lines, invalid, _, err := collectCartLines(tx, customerId, false)
+	if err != nil || len(invalid) > 0 {
+		return nil, invalid, err
+	}
+
+	pricing, err := priceCart(tx, cart, lines)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := applyTaxes(tx, pricing); err != nil {
+		return nil, nil, err
+	}
+
+	return pricing, nil, nil
+}
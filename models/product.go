@@ -4,22 +4,27 @@ import (
 	"time"
 
 	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
 	"gorm.io/gorm"
 )
 
 type Product struct {
-	ID             uint64     `gorm:"primaryKey"`
-	Name           string     `gorm:"not null"`
-	Description    *string    `gorm:"column:description;null;type:text"`
-	Price          float64    `gorm:"not null;type:float"`
-	Stock          int        `gorm:"not null;type:int"`
-	Thumbnail      string     `gorm:"not null;type:varchar"`
-	CategoryID     uint64     `gorm:"not null;column:category_id"`
-	ShipmentWeight float64    `gorm:"not null"`
-	IsActive       *bool      `gorm:"default:true"`
-	IsDelete       *bool      `gorm:"default:false"`
-	ModifiedAt     *time.Time `gorm:"type:timestamp with time zone"`
-	CreatedAt      time.Time  `gorm:"type:timestamp with time zone;default:now()"`
+	ID             uint64      `gorm:"primaryKey"`
+	Name           string      `gorm:"not null"`
+	Description    *string     `gorm:"column:description;null;type:text"`
+	Price          utils.Money `gorm:"not null"`
+	Stock          int         `gorm:"not null;type:int"`
+	Thumbnail      string      `gorm:"not null;type:varchar"`
+	CategoryID     uint64      `gorm:"not null;column:category_id"`
+	ShipmentWeight float64     `gorm:"not null"`
+	IsGiftCard     *bool       `gorm:"default:false"`
+	IsActive       *bool       `gorm:"default:true"`
+	IsDelete       *bool       `gorm:"default:false"`
+	ModifiedAt     *time.Time  `gorm:"type:timestamp with time zone"`
+	CreatedAt      time.Time   `gorm:"type:timestamp with time zone;default:now()"`
+
+	// SearchVector is kept up to date by refreshSearchIndex
+	SearchVector string `gorm:"type:tsvector;index:idx_product_search_vector,type:gin;->:false;<-:false" json:"-"`
 
 	// Relations
 	CartProducts    []CartProduct    `gorm:"foreignKey:ProductID" json:"-"`
@@ -27,6 +32,8 @@ type Product struct {
 	CompareProducts []CompareProduct `gorm:"foreignKey:ProductID" json:"-"`
 	OrderProducts   []OrderProduct   `gorm:"foreignKey:ProductID" json:"-"`
 	Specifications  []Specification  `gorm:"foreignKey:ProductID" json:"-"`
+	Options         []ProductOption  `gorm:"foreignKey:ProductID" json:",omitempty"`
+	Variants        []ProductVariant `gorm:"foreignKey:ProductID" json:",omitempty"`
 }
 
 func (Product) TableName() string {
@@ -45,7 +52,7 @@ func NewProductService(db *gorm.DB) *ProductService {
 	}
 }
 
-func (p *ProductService) GetAll(catId, productId uint64, minPrice, maxPrice float64, name, sortBy, order string, take, skip int) (*[]Product, error) {
+func (p *ProductService) GetAll(catId, productId uint64, minPrice, maxPrice utils.Money, name, sortBy, order string, take, skip int) (*[]Product, error) {
 	var products []Product
 	query := p.repo.GetQuery().Where("category_id = ?", catId)
 
@@ -76,20 +83,25 @@ func (p *ProductService) GetAll(catId, productId uint64, minPrice, maxPrice floa
 	return &products, query.Error
 }
 
-func (p *ProductService) GetAllActive(productId, categoryId uint64, minPrice, maxPrice float64, name, sortBy, order string, take, skip int) (*[]Product, error) {
+// GetAllActive returns the active products matching the filters, along with
+// the facets of the filterable specifications over all of them. specs maps
+// specification keys to the values products must have one of, keys that are
+// not filterable are ignored.
+func (p *ProductService) GetAllActive(productId, categoryId uint64, minPrice, maxPrice utils.Money, name string, specs map[string][]string, sortBy, order string, take, skip int) (*[]Product, []SpecificationFacet, error) {
 	var products []Product
-	query := p.repo.GetQuery().
-		Joins("JOIN category ON category.id = product.category_id").
-		Where("product.is_active = ? AND product.is_delete = ? AND category.is_active = ? AND category.is_delete = ?", true, false, true, false)
+	base := func() *gorm.DB {
+		query := p.repo.GetQuery().Model(&Product{}).
+			Joins("JOIN category ON category.id = product.category_id").
+			Where("product.is_active = ? AND product.is_delete = ? AND category.is_active = ? AND category.is_delete = ?", true, false, true, false)
+		if productId > 0 {
+			return query.Where("product.id = ?", productId)
+		}
 
-	if productId > 0 {
-		query = query.Where("product.id = ?", productId).Limit(1).Find(&products)
-	} else {
 		if categoryId > 0 {
 			query = query.Where("product.category_id = ?", categoryId)
 		}
-		if name != "" {
-			query = query.Where("product.name LIKE ?", "%"+name+"%")
+		if search := searchQuery(name); search != "" {
+			query = query.Where("product.search_vector @@ to_tsquery('"+searchConfig+"', ?)", search)
 		}
 		if minPrice > 0 {
 			query = query.Where("product.price >= ?", minPrice)
@@ -97,29 +109,58 @@ func (p *ProductService) GetAllActive(productId, categoryId uint64, minPrice, ma
 		if maxPrice > 0 {
 			query = query.Where("product.price <= ?", maxPrice)
 		}
+		return query
+	}
 
-		if sortBy != "" {
-			if order == "desc" {
-				query = query.Order("product." + sortBy + " desc")
-			} else {
-				query = query.Order("product." + sortBy + " asc")
-			}
+	if productId > 0 {
+		err := preloadVariantMatrix(base()).Limit(1).Find(&products).Error
+		return &products, nil, err
+	}
+
+	filters, specs, err := filterableSpecs(p.repo.GetQuery(), specs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := preloadVariantMatrix(filterBySpecs(base(), specs, ""))
+	if sortBy != "" {
+		if order == "desc" {
+			query = query.Order("product." + sortBy + " desc")
+		} else {
+			query = query.Order("product." + sortBy + " asc")
 		}
-		query = query.Offset(skip).Limit(take).Find(&products)
+	}
+	if err := query.Offset(skip).Limit(take).Find(&products).Error; err != nil {
+		return nil, nil, err
 	}
 
-	return &products, query.Error
+	facets, err := specificationFacets(p.repo.GetQuery(), base, filters, specs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &products, facets, nil
 }
 
 func (p *ProductService) Create(product Product) error {
-	return p.repo.Create(&product)
+	return p.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		return refreshSearchIndex(tx, product.ID)
+	})
 }
 
 func (p *ProductService) Update(product *Product) error {
 	if product.Stock == 0 {
-		p.cartProductService.repo.GetQuery().Where("product_id = ?", product.ID).Delete(&CartProduct{})
+		p.cartProductService.repo.GetQuery().Where("product_id = ? AND variant_id IS NULL", product.ID).Delete(&CartProduct{})
 	}
-	return p.repo.Update(product)
+	return p.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(product).Error; err != nil {
+			return err
+		}
+		return refreshSearchIndex(tx, product.ID)
+	})
 }
 
 func (p *ProductService) GetById(id uint64) (*Product, error) {
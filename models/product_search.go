@@ -0,0 +1,122 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+)
+
+// searchConfig is the text search configuration products are indexed with.
+// Postgres has no Persian dictionary, so words are only lower cased.
+const searchConfig = "simple"
+
+// ProductSearchResult is a product found by Search, along with how well it
+// matched and the parts of its name and description that matched, wrapped
+// in <mark> tags.
+type ProductSearchResult struct {
+	Product
+	Rank      float64
+	Highlight ProductHighlight
+}
+
+type ProductHighlight struct {
+	Name        string
+	Description string `json:",omitempty"`
+}
+
+// refreshSearchIndex rebuilds the search vector of the given products from
+// their name, specifications and description, weighted in that order.
+func refreshSearchIndex(tx *gorm.DB, productIds ...uint64) error {
+	if len(productIds) == 0 {
+		return nil
+	}
+	return tx.Exec(`UPDATE product SET search_vector = `+searchVectorSQL+` WHERE id IN ?`, productIds).Error
+}
+
+// IndexProducts builds the search vector of products that don't have one yet,
+// such as those created before products were searchable.
+func IndexProducts(db *gorm.DB) error {
+	return db.Exec(`UPDATE product SET search_vector = ` + searchVectorSQL + ` WHERE search_vector IS NULL`).Error
+}
+
+var searchVectorSQL = `setweight(to_tsvector('` + searchConfig + `', ` + utils.NormalizePersianSQL("product.name") + `), 'A') ||
+	setweight(to_tsvector('` + searchConfig + `', ` + utils.NormalizePersianSQL(`coalesce((SELECT string_agg(specification.key || ' ' || specification.value, ' ')
+		FROM specification WHERE specification.product_id = product.id AND specification.is_active), '')`) + `), 'B') ||
+	setweight(to_tsvector('` + searchConfig + `', ` + utils.NormalizePersianSQL("coalesce(product.description, '')") + `), 'C')`
+
+// searchQuery turns what a customer typed into a text search query matching
+// products with every word, or a word starting with it, so results show up
+// while the last word is still being typed. It returns "" when text has no
+// words.
+func searchQuery(text string) string {
+	words := strings.FieldsFunc(utils.NormalizePersian(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i := range words {
+		words[i] += ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// Search finds the active products matching text, best matches first.
+func (p *ProductService) Search(text string, categoryId uint64, take, skip int) (*[]ProductSearchResult, error) {
+	results := []ProductSearchResult{}
+	query := searchQuery(text)
+	if query == "" {
+		return &results, nil
+	}
+
+	var hits []struct {
+		ID                   uint64
+		Rank                 float64
+		NameHighlight        string
+		DescriptionHighlight string
+	}
+	tsQuery := "to_tsquery('" + searchConfig + "', ?)"
+	search := p.repo.GetQuery().Table("product").
+		Select(`product.id, ts_rank(product.search_vector, `+tsQuery+`) AS rank,
+			ts_headline('`+searchConfig+`', `+utils.NormalizePersianSQL("product.name")+`, `+tsQuery+`, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS name_highlight,
+			ts_headline('`+searchConfig+`', `+utils.NormalizePersianSQL("coalesce(product.description, '')")+`, `+tsQuery+`, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5') AS description_highlight`,
+			query, query, query).
+		Joins("JOIN category ON category.id = product.category_id").
+		Where("product.is_active = ? AND product.is_delete = ? AND category.is_active = ? AND category.is_delete = ?", true, false, true, false).
+		Where("product.search_vector @@ "+tsQuery, query)
+	if categoryId > 0 {
+		search = search.Where("product.category_id = ?", categoryId)
+	}
+	if err := search.Order("rank DESC, product.id").Offset(skip).Limit(take).Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+	if len(hits) == 0 {
+		return &results, nil
+	}
+
+	ids := make([]uint64, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	var products []Product
+	if err := preloadVariantMatrix(p.repo.GetQuery()).Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	productsMap := make(map[uint64]*Product, len(products))
+	for i := range products {
+		productsMap[products[i].ID] = &products[i]
+	}
+
+	for _, hit := range hits {
+		product, ok := productsMap[hit.ID]
+		if !ok {
+			continue
+		}
+		result := ProductSearchResult{Product: *product, Rank: hit.Rank}
+		result.Highlight.Name = hit.NameHighlight
+		if strings.Contains(hit.DescriptionHighlight, "<mark>") {
+			result.Highlight.Description = hit.DescriptionHighlight
+		}
+		results = append(results, result)
+	}
+	return &results, nil
+}
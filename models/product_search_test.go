@@ -0,0 +1,31 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+// searchQuery turns every word into a prefix term and joins them with &.
+func TestSearchQuery(t *testing.T) {
+	for in, words := range map[string][]string{
+		"گوشی":               {"گوشی"},
+		"گوشی سامسونگ":       {"گوشی", "سامسونگ"},
+		"  گوشی   سامسونگ  ": {"گوشی", "سامسونگ"},
+		"كيف":                {"کیف"},
+		"آيفون ۱۳":           {"آیفون", "13"},
+		"می\u200cخواهم":      {"می", "خواهم"},
+		"USB-C":              {"USB", "C"},
+		"a'b | c & d:*":      {"a", "b", "c", "d"},
+	} {
+		want := strings.Join(words, ":* & ") + ":*"
+		if got := searchQuery(in); got != want {
+			t.Errorf("searchQuery(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "   ", "!?-"} {
+		if got := searchQuery(in); got != "" {
+			t.Errorf("searchQuery(%q) = %q, want nothing to search for", in, got)
+		}
+	}
+}
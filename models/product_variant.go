@@ -0,0 +1,323 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProductOption is a way a product comes in, such as size or color.
+type ProductOption struct {
+	ID        uint64 `gorm:"primaryKey"`
+	ProductID uint64 `gorm:"not null;index"`
+	Name      string `gorm:"not null"`
+	Position  int    `gorm:"not null;default:0"`
+
+	// Relations
+	Values []ProductOptionValue `gorm:"foreignKey:OptionID;constraint:OnDelete:CASCADE;"`
+}
+
+func (ProductOption) TableName() string {
+	return "product_option"
+}
+
+type ProductOptionValue struct {
+	ID       uint64 `gorm:"primaryKey"`
+	OptionID uint64 `gorm:"not null;index"`
+	Value    string `gorm:"not null"`
+	Position int    `gorm:"not null;default:0"`
+}
+
+func (ProductOptionValue) TableName() string {
+	return "product_option_value"
+}
+
+// ProductVariant is one combination of the values of a product's options,
+// with a value of every option. It is sold at Price and weighs Weight, or
+// what the product does when they are not set, and has a stock of its own.
+// Title names its values in the order of the options, e.g. "L / قرمز".
+type ProductVariant struct {
+	ID         uint64       `gorm:"primaryKey"`
+	ProductID  uint64       `gorm:"not null;index"`
+	SKU        string       `gorm:"not null;unique"`
+	Barcode    *string      `gorm:"null;unique"`
+	Title      string       `gorm:"not null"`
+	Price      *utils.Money `gorm:"null"`
+	Stock      int          `gorm:"not null;default:0"`
+	Weight     *float64     `gorm:"null"`
+	IsActive   *bool        `gorm:"default:true"`
+	IsDelete   *bool        `gorm:"default:false"`
+	ModifiedAt *time.Time   `gorm:"type:timestamp with time zone"`
+	CreatedAt  time.Time    `gorm:"type:timestamp with time zone;default:now()"`
+
+	// Relations
+	Values []ProductOptionValue `gorm:"many2many:product_variant_value"`
+	Images []ImageProduct       `gorm:"foreignKey:VariantID"`
+}
+
+func (ProductVariant) TableName() string {
+	return "product_variant"
+}
+
+func (v *ProductVariant) price(product *Product) utils.Money {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return product.Price
+}
+
+func (v *ProductVariant) weight(product *Product) float64 {
+	if v.Weight != nil {
+		return *v.Weight
+	}
+	return product.ShipmentWeight
+}
+
+// Available returns whether the variant is on sale.
+func (v *ProductVariant) Available() bool {
+	return (v.IsActive == nil || *v.IsActive) && (v.IsDelete == nil || !*v.IsDelete)
+}
+
+type ProductVariantService struct {
+	repo repository.Repository[ProductVariant]
+}
+
+func NewProductVariantService(db *gorm.DB) *ProductVariantService {
+	return &ProductVariantService{
+		repo: repository.NewGenericRepository[ProductVariant](db),
+	}
+}
+
+func preloadOptionValues(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+// preloadVariantMatrix preloads the options of products with their values
+// and every variant on sale with the values it is made of and its images.
+func preloadVariantMatrix(db *gorm.DB) *gorm.DB {
+	return db.Preload("Options", preloadOptionValues).Preload("Options.Values", preloadOptionValues).
+		Preload("Variants", "is_active = ? AND is_delete = ?", true, false).
+		Preload("Variants.Values", preloadOptionValues).
+		Preload("Variants.Images", "is_active = ? AND is_delete = ?", true, false)
+}
+
+func (p *ProductVariantService) GetOptions(productId uint64) (*[]ProductOption, error) {
+	var options []ProductOption
+	err := p.repo.GetQuery().Where("product_id = ?", productId).Order("position, id").Preload("Values", preloadOptionValues).Find(&options).Error
+	return &options, err
+}
+
+// CreateOption adds an option to a product along with its values. Options
+// can only be added while the product has no variants, since every variant
+// needs a value of every option.
+func (p *ProductVariantService) CreateOption(option *ProductOption) error {
+	if len(option.Values) == 0 {
+		return errors.New("ویژگی باید حداقل یک مقدار داشته باشد")
+	}
+
+	return p.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		var variants int64
+		if err := tx.Model(&ProductVariant{}).Where("product_id = ? AND is_delete = ?", option.ProductID, false).Count(&variants).Error; err != nil {
+			return err
+		}
+		if variants > 0 {
+			return errors.New("برای افزودن ویژگی ابتدا تنوع های محصول را حذف کنید")
+		}
+		return tx.Create(option).Error
+	})
+}
+
+// DeleteOption removes an option no variant is using.
+func (p *ProductVariantService) DeleteOption(productId, id uint64) error {
+	return p.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		var option ProductOption
+		if err := tx.Where("id = ? AND product_id = ?", id, productId).First(&option).Error; err != nil {
+			return errors.New("ویژگی مورد نظر یافت نشد")
+		}
+
+		var used int64
+		if err := tx.Table("product_variant_value").
+			Joins("JOIN product_option_value ON product_option_value.id = product_variant_value.product_option_value_id").
+			Joins("JOIN product_variant ON product_variant.id = product_variant_value.product_variant_id").
+			Where("product_option_value.option_id = ? AND product_variant.is_delete = ?", id, false).
+			Count(&used).Error; err != nil {
+			return err
+		}
+		if used > 0 {
+			return errors.New("این ویژگی در تنوع های محصول استفاده شده است")
+		}
+
+		if err := tx.Where("option_id = ?", id).Delete(&ProductOptionValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&option).Error
+	})
+}
+
+// GetAll returns the variants of a product that were not deleted.
+func (p *ProductVariantService) GetAll(productId uint64) (*[]ProductVariant, error) {
+	var variants []ProductVariant
+	err := p.repo.GetQuery().Where("product_id = ? AND is_delete = ?", productId, false).Order("id").
+		Preload("Values", preloadOptionValues).Preload("Images", "is_delete = ?", false).Find(&variants).Error
+	return &variants, err
+}
+
+func (p *ProductVariantService) GetById(id uint64) (*ProductVariant, error) {
+	var variant ProductVariant
+	res := p.repo.GetQuery().Where("is_delete = ?", false).Preload("Values", preloadOptionValues).First(&variant, id)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("تنوع محصول مورد نظر یافت نشد")
+	}
+	return &variant, res.Error
+}
+
+// Save creates or updates a variant with the option values valueIds, which
+// must hold one value of each of the product's options and differ from the
+// values of every other variant of the product.
+func (p *ProductVariantService) Save(variant *ProductVariant, valueIds []uint64) error {
+	return p.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		var options []ProductOption
+		if err := tx.Where("product_id = ?", variant.ProductID).Order("position, id").Preload("Values").Find(&options).Error; err != nil {
+			return err
+		}
+		if len(options) == 0 {
+			return errors.New("برای ساخت تنوع ابتدا ویژگی های محصول را تعریف کنید")
+		}
+
+		chosen := make(map[uint64]bool, len(valueIds))
+		for _, id := range valueIds {
+			chosen[id] = true
+		}
+
+		var values []ProductOptionValue
+		var title []string
+		for _, option := range options {
+			var picked *ProductOptionValue
+			for i, value := range option.Values {
+				if !chosen[value.ID] {
+					continue
+				}
+				if picked != nil {
+					return fmt.Errorf("برای ویژگی %v فقط یک مقدار می توان انتخاب کرد", option.Name)
+				}
+				picked = &option.Values[i]
+			}
+			if picked == nil {
+				return fmt.Errorf("مقدار ویژگی %v انتخاب نشده است", option.Name)
+			}
+			values = append(values, *picked)
+			title = append(title, picked.Value)
+		}
+		if len(values) != len(chosen) {
+			return errors.New("مقادیر انتخاب شده متعلق به ویژگی های این محصول نیستند")
+		}
+
+		var others []ProductVariant
+		if err := tx.Where("product_id = ? AND id <> ? AND is_delete = ?", variant.ProductID, variant.ID, false).Preload("Values").Find(&others).Error; err != nil {
+			return err
+		}
+		key := variantKey(values)
+		for _, other := range others {
+			if variantKey(other.Values) == key {
+				return fmt.Errorf("تنوع %v قبلا برای این محصول ثبت شده است", other.Title)
+			}
+		}
+
+		var sameSKU int64
+		if err := tx.Model(&ProductVariant{}).Where("sku = ? AND id <> ?", variant.SKU, variant.ID).Count(&sameSKU).Error; err != nil {
+			return err
+		}
+		if sameSKU > 0 {
+			return fmt.Errorf("کد SKU %v تکراری است", variant.SKU)
+		}
+
+		variant.Title = strings.Join(title, " / ")
+		if err := tx.Omit(clause.Associations).Save(variant).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(variant).Association("Values").Replace(values); err != nil {
+			return err
+		}
+		variant.Values = values
+
+		if variant.Stock == 0 {
+			return tx.Where("variant_id = ?", variant.ID).Delete(&CartProduct{}).Error
+		}
+		return nil
+	})
+}
+
+// Delete hides a variant from the shop and takes it out of every cart. It
+// is kept in the database for the orders that bought it.
+func (p *ProductVariantService) Delete(id uint64) error {
+	return p.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&ProductVariant{}).Where("id = ?", id).Updates(map[string]any{"is_delete": true, "modified_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		return tx.Where("variant_id = ?", id).Delete(&CartProduct{}).Error
+	})
+}
+
+// SameVariant returns whether two lines of a product are of the same variant,
+// lines without a variant being the same as each other.
+func SameVariant(a, b *uint64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func variantKey(values []ProductOptionValue) string {
+	ids := make([]uint64, 0, len(values))
+	for _, value := range values {
+		ids = append(ids, value.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return fmt.Sprint(ids)
+}
+
+// loadVariants sets the Variants of products to their variants that were not
+// deleted, locking them in id order when lock is set.
+func loadVariants(tx *gorm.DB, products map[uint64]*Product, lock bool) error {
+	ids := make([]uint64, 0, len(products))
+	for id := range products {
+		products[id].Variants = nil
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := tx
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+
+	var variants []ProductVariant
+	if err := query.Where("product_id IN ? AND is_delete = ?", ids, false).Order("id").Find(&variants).Error; err != nil {
+		return err
+	}
+	for _, variant := range variants {
+		product := products[variant.ProductID]
+		product.Variants = append(product.Variants, variant)
+	}
+	return nil
+}
+
+// variant returns the variant of p with the given id, which must have been
+// loaded by loadVariants.
+func (p *Product) variant(id uint64) *ProductVariant {
+	for i := range p.Variants {
+		if p.Variants[i].ID == id {
+			return &p.Variants[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PromotionType string
+
+const (
+	// every BuyQuantity+GetQuantity items in scope, the GetQuantity cheapest are free
+	PromotionTypeBuyXGetY PromotionType = "buy_x_get_y"
+	// Percentage off every item in scope, e.g. a category-wide sale
+	PromotionTypePercentage PromotionType = "percentage"
+	// the percentage of the highest tier the basket reaches off every item in scope
+	PromotionTypeTiered PromotionType = "tiered"
+	// shipping is free
+	PromotionTypeFreeShipping PromotionType = "free_shipping"
+)
+
+func (t PromotionType) IsValid() bool {
+	switch t {
+	case PromotionTypeBuyXGetY, PromotionTypePercentage, PromotionTypeTiered, PromotionTypeFreeShipping:
+		return true
+	default:
+		return false
+	}
+}
+
+type Promotion struct {
+	ID          uint64        `gorm:"primaryKey"`
+	Name        string        `gorm:"not null"`
+	Type        PromotionType `gorm:"not null"`
+	CategoryID  *uint64       `gorm:"null"`
+	ProductID   *uint64       `gorm:"null"`
+	BuyQuantity int           `gorm:"not null;default:0"`
+	GetQuantity int           `gorm:"not null;default:0"`
+	Percentage  float64       `gorm:"not null;default:0"`
+	MinBasket   *utils.Money  `gorm:"null"`
+	// Weekdays the promotion runs on as comma separated numbers, 0 being
+	// Sunday and 5 Friday, every day when empty
+	Weekdays   string     `gorm:"not null;default:''"`
+	StartsAt   *time.Time `gorm:"type:timestamp with time zone"`
+	ExpiresAt  *time.Time `gorm:"type:timestamp with time zone"`
+	Priority   int        `gorm:"not null;default:0"`
+	IsActive   *bool      `gorm:"default:true"`
+	ModifiedAt *time.Time `gorm:"type:timestamp with time zone"`
+	CreatedAt  time.Time  `gorm:"type:timestamp with time zone;default:now()"`
+
+	// Relations
+	Tiers []PromotionTier `gorm:"foreignKey:PromotionID"`
+}
+
+func (Promotion) TableName() string {
+	return "promotion"
+}
+
+type PromotionTier struct {
+	ID          uint64      `gorm:"primaryKey"`
+	PromotionID uint64      `gorm:"not null;index"`
+	MinAmount   utils.Money `gorm:"not null"`
+	Percentage  float64     `gorm:"not null"`
+}
+
+func (PromotionTier) TableName() string {
+	return "promotion_tier"
+}
+
+// isRunning reports whether the promotion applies at now.
+func (p *Promotion) isRunning(now time.Time) bool {
+	if p.IsActive != nil && !*p.IsActive {
+		return false
+	}
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return false
+	}
+	if p.ExpiresAt != nil && now.After(*p.ExpiresAt) {
+		return false
+	}
+	if p.Weekdays == "" {
+		return true
+	}
+	for _, day := range strings.Split(p.Weekdays, ",") {
+		if weekday, err := strconv.Atoi(strings.TrimSpace(day)); err == nil && time.Weekday(weekday) == now.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Promotion) validate() error {
+	if !p.Type.IsValid() {
+		return errors.New("نوع تخفیف خودکار نامعتبر است")
+	}
+	switch p.Type {
+	case PromotionTypeBuyXGetY:
+		if p.BuyQuantity < 1 || p.GetQuantity < 1 {
+			return errors.New("تعداد خرید و تعداد رایگان را وارد کنید")
+		}
+	case PromotionTypePercentage:
+		if p.Percentage <= 0 || p.Percentage > 100 {
+			return errors.New("درصد تخفیف باید بین ۰ و ۱۰۰ باشد")
+		}
+	case PromotionTypeTiered:
+		if len(p.Tiers) == 0 {
+			return errors.New("پله های تخفیف را وارد کنید")
+		}
+		for _, tier := range p.Tiers {
+			if tier.Percentage <= 0 || tier.Percentage > 100 {
+				return errors.New("درصد تخفیف باید بین ۰ و ۱۰۰ باشد")
+			}
+		}
+	}
+	for _, day := range strings.Split(p.Weekdays, ",") {
+		if weekday, err := strconv.Atoi(strings.TrimSpace(day)); p.Weekdays != "" && (err != nil || weekday < 0 || weekday > 6) {
+			return fmt.Errorf("روز هفته %v نامعتبر است", day)
+		}
+	}
+	return nil
+}
+
+type PromotionService struct {
+	repo repository.Repository[Promotion]
+}
+
+func NewPromotionService(db *gorm.DB) *PromotionService {
+	return &PromotionService{
+		repo: repository.NewGenericRepository[Promotion](db),
+	}
+}
+
+func (p *PromotionService) GetAll(take, skip int) (*[]Promotion, error) {
+	var promotions []Promotion
+	err := p.repo.GetQuery().Order("priority desc, id").Offset(skip).Limit(take).Preload("Tiers").Find(&promotions).Error
+	return &promotions, err
+}
+
+func (p *PromotionService) GetById(id uint64) (*Promotion, error) {
+	var promotion Promotion
+	res := p.repo.GetQuery().Preload("Tiers").First(&promotion, id)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("تخفیف خودکار یافت نشد")
+	}
+	return &promotion, res.Error
+}
+
+// Save creates or updates a promotion and replaces its tiers.
+func (p *PromotionService) Save(promotion *Promotion) error {
+	if err := promotion.validate(); err != nil {
+		return err
+	}
+
+	return p.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(promotion).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("promotion_id = ?", promotion.ID).Delete(&PromotionTier{}).Error; err != nil {
+			return err
+		}
+		for i := range promotion.Tiers {
+			promotion.Tiers[i].ID = 0
+			promotion.Tiers[i].PromotionID = promotion.ID
+		}
+		if len(promotion.Tiers) > 0 {
+			return tx.Create(&promotion.Tiers).Error
+		}
+		return nil
+	})
+}
+
+// applyPromotions gives the discounts of every promotion running at now, the
+// ones with the highest priority first, so later ones discount what is left.
+func applyPromotions(tx *gorm.DB, pricing *CartPricing, now time.Time) error {
+	var promotions []Promotion
+	if err := tx.Where("is_active = ?", true).Order("priority desc, id").Preload("Tiers").Find(&promotions).Error; err != nil {
+		return err
+	}
+
+	for i := range promotions {
+		promotion := &promotions[i]
+		if !promotion.isRunning(now) {
+			continue
+		}
+
+		var categoryIds, productIds []uint64
+		if promotion.CategoryID != nil {
+			categoryIds = append(categoryIds, *promotion.CategoryID)
+		}
+		if promotion.ProductID != nil {
+			productIds = append(productIds, *promotion.ProductID)
+		}
+		inScope, err := lineScope(tx, categoryIds, productIds)
+		if err != nil {
+			return err
+		}
+
+		var scoped []int
+		var basket utils.Money
+		for i, line := range pricing.Lines {
+			if inScope(line) {
+				scoped = append(scoped, i)
+				basket += line.Total
+			}
+		}
+		if len(scoped) == 0 || (promotion.MinBasket != nil && basket < *promotion.MinBasket) {
+			continue
+		}
+
+		adjustment := PriceAdjustment{
+			Source:   PriceAdjustmentPromotion,
+			SourceID: promotion.ID,
+			Label:    promotion.Name,
+		}
+
+		switch promotion.Type {
+		case PromotionTypePercentage:
+			percentOff(pricing.Lines, scoped, promotion.Percentage, adjustment)
+		case PromotionTypeTiered:
+			var percentage float64
+			var reached utils.Money
+			for _, tier := range promotion.Tiers {
+				if basket >= tier.MinAmount && tier.MinAmount >= reached {
+					percentage, reached = tier.Percentage, tier.MinAmount
+				}
+			}
+			percentOff(pricing.Lines, scoped, percentage, adjustment)
+		case PromotionTypeBuyXGetY:
+			buyXGetY(pricing.Lines, scoped, promotion.BuyQuantity, promotion.GetQuantity, adjustment)
+		case PromotionTypeFreeShipping:
+			if pricing.FreeShipping == nil {
+				pricing.FreeShipping = &adjustment
+			}
+		}
+	}
+
+	return nil
+}
+
+func percentOff(lines []PricedLine, scoped []int, percentage float64, adjustment PriceAdjustment) {
+	if percentage <= 0 {
+		return
+	}
+	for _, i := range scoped {
+		adjustment.Amount = min(lines[i].Total.Percent(percentage), lines[i].Total)
+		lines[i].addAdjustment(adjustment)
+	}
+}
+
+// buyXGetY makes the cheapest items free, GetQuantity of them for every
+// BuyQuantity+GetQuantity items in scope.
+func buyXGetY(lines []PricedLine, scoped []int, buy, get int, adjustment PriceAdjustment) {
+	var items int
+	for _, i := range scoped {
+		items += lines[i].Quantity
+	}
+	free := items / (buy + get) * get
+	if free == 0 {
+		return
+	}
+
+	cheapest := append([]int(nil), scoped...)
+	sort.SliceStable(cheapest, func(a, b int) bool {
+		return lines[cheapest[a]].Total.Times(lines[cheapest[b]].Quantity) < lines[cheapest[b]].Total.Times(lines[cheapest[a]].Quantity)
+	})
+
+	for _, i := range cheapest {
+		if free == 0 {
+			return
+		}
+		quantity := lines[i].Quantity
+		if quantity > free {
+			quantity = free
+		}
+		free -= quantity
+
+		adjustment.Amount = lines[i].Total.Share(utils.Money(quantity), utils.Money(lines[i].Quantity))
+		lines[i].addAdjustment(adjustment)
+	}
+}
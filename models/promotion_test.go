@@ -0,0 +1,88 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/Hello256World/shop-api/utils"
+)
+
+func pricedLine(quantity int, total utils.Money) PricedLine {
+	return PricedLine{Quantity: quantity, Subtotal: total, Total: total}
+}
+
+func checkDiscounts(t *testing.T, lines []PricedLine, want ...utils.Money) {
+	t.Helper()
+	for i, line := range lines {
+		if line.Discount != want[i] {
+			t.Errorf("line %v: discount = %v, want %v", i, line.Discount, want[i])
+		}
+		if line.Total != line.Subtotal-line.Discount {
+			t.Errorf("line %v: total = %v for %v with %v off", i, line.Total, line.Subtotal, line.Discount)
+		}
+	}
+}
+
+func TestBuyXGetY(t *testing.T) {
+	promotion := PriceAdjustment{Source: PriceAdjustmentPromotion, SourceID: 1}
+
+	t.Run("one line", func(t *testing.T) {
+		lines := []PricedLine{pricedLine(3, 300)}
+		buyXGetY(lines, []int{0}, 2, 1, promotion)
+		checkDiscounts(t, lines, 100)
+	})
+	t.Run("not enough items", func(t *testing.T) {
+		lines := []PricedLine{pricedLine(2, 200)}
+		buyXGetY(lines, []int{0}, 2, 1, promotion)
+		checkDiscounts(t, lines, 0)
+	})
+	t.Run("every full set", func(t *testing.T) {
+		lines := []PricedLine{pricedLine(5, 1000)}
+		buyXGetY(lines, []int{0}, 1, 1, promotion)
+		checkDiscounts(t, lines, 400)
+	})
+	t.Run("cheapest line is free", func(t *testing.T) {
+		lines := []PricedLine{pricedLine(2, 1000), pricedLine(1, 100)}
+		buyXGetY(lines, []int{0, 1}, 2, 1, promotion)
+		checkDiscounts(t, lines, 0, 100)
+	})
+	t.Run("cheapest by unit price", func(t *testing.T) {
+		lines := []PricedLine{pricedLine(4, 400), pricedLine(1, 150)}
+		buyXGetY(lines, []int{0, 1}, 4, 1, promotion)
+		checkDiscounts(t, lines, 100, 0)
+	})
+	t.Run("free items span lines", func(t *testing.T) {
+		lines := []PricedLine{pricedLine(3, 900), pricedLine(1, 100)}
+		buyXGetY(lines, []int{0, 1}, 1, 1, promotion)
+		checkDiscounts(t, lines, 300, 100)
+	})
+	t.Run("lines out of scope", func(t *testing.T) {
+		lines := []PricedLine{pricedLine(3, 300), pricedLine(1, 10)}
+		buyXGetY(lines, []int{0}, 2, 1, promotion)
+		checkDiscounts(t, lines, 100, 0)
+	})
+}
+
+func TestPercentOff(t *testing.T) {
+	promotion := PriceAdjustment{Source: PriceAdjustmentPromotion, SourceID: 1}
+
+	t.Run("lines in scope", func(t *testing.T) {
+		lines := []PricedLine{pricedLine(1, 1000), pricedLine(2, 2000), pricedLine(1, 500)}
+		percentOff(lines, []int{0, 1}, 10, promotion)
+		checkDiscounts(t, lines, 100, 200, 0)
+	})
+	t.Run("rounds to the rial", func(t *testing.T) {
+		lines := []PricedLine{pricedLine(1, 2005)}
+		percentOff(lines, []int{0}, 10, promotion)
+		checkDiscounts(t, lines, 201)
+	})
+	t.Run("no more than the line", func(t *testing.T) {
+		lines := []PricedLine{pricedLine(1, 1000)}
+		percentOff(lines, []int{0}, 150, promotion)
+		checkDiscounts(t, lines, 1000)
+	})
+	t.Run("no percentage", func(t *testing.T) {
+		lines := []PricedLine{pricedLine(1, 1000)}
+		percentOff(lines, []int{0}, 0, promotion)
+		checkDiscounts(t, lines, 0)
+	})
+}
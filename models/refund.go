@@ -0,0 +1,417 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RefundMethod string
+
+const (
+	// the money goes back to the card the order was paid with, anything
+	// paid with the wallet goes back to the wallet. Gateways that can only
+	// pay back a payment in full, such as Zarinpal, refund partial amounts
+	// to the wallet instead, the refund's WalletAmount shows how much.
+	RefundMethodGateway RefundMethod = "gateway"
+	// all of the money goes to the customer's wallet
+	RefundMethodWallet RefundMethod = "wallet"
+)
+
+func (m RefundMethod) isValid() bool {
+	switch m {
+	case RefundMethodGateway, RefundMethodWallet:
+		return true
+	default:
+		return false
+	}
+}
+
+// RefundLine asks for quantity items of an order line to be refunded.
+type RefundLine struct {
+	OrderProductID uint64 `json:"order_product_id" form:"order_product_id" binding:"required"`
+	Quantity       int    `json:"quantity" form:"quantity" binding:"required,gt=0"`
+}
+
+type RefundInput struct {
+	// Lines to refund, every line that is not refunded yet when empty
+	Lines  []RefundLine
+	Method RefundMethod
+	Reason string
+	// Restock puts the refunded items back into the products' stock
+	Restock bool
+	// Status the order moves to once it is refunded entirely
+	Status    OrderStatus
+	ChangedBy string
+
+	// onClaim, onUnclaim and onComplete run in the database transactions
+	// claiming, giving back and completing the refund, for what the refund
+	// is made for to follow it
+	onClaim    func(tx *gorm.DB) error
+	onUnclaim  func(tx *gorm.DB) error
+	onComplete func(tx *gorm.DB, refund *Transaction) error
+}
+
+type RefundService struct {
+	repo repository.Repository[Transaction]
+}
+
+func NewRefundService(db *gorm.DB) *RefundService {
+	return &RefundService{
+		repo: repository.NewGenericRepository[Transaction](db),
+	}
+}
+
+// Refund pays back some or all of the lines of a paid order and records it
+// as a refund transaction pointing at the order's payment.
+//
+// The refunded quantities are claimed on the order lines before the money is
+// sent back, so two refunds of the same line can never both go through, and
+// they are given back if the gateway refuses the refund.
+func (r *RefundService) Refund(order *Order, input RefundInput) (*Transaction, error) {
+	if !input.Method.isValid() {
+		return nil, errors.New("روش بازپرداخت نامعتبر است")
+	}
+
+	payment, refund, lines, err := r.claim(order, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.payBack(payment, refund, input.Method); err != nil {
+		failureCause := err.Error()
+		refund.Status = TransactionStatusFailed
+		refund.FailureCause = &failureCause
+
+		if unclaimErr := r.unclaim(refund, lines, input); unclaimErr != nil {
+			return nil, unclaimErr
+		}
+		return refund, fmt.Errorf("خطا در بازپرداخت وجه: %v", err)
+	}
+
+	if err := r.complete(order, payment, refund, lines, input); err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// claim creates the refund transaction and marks the refunded quantities on
+// the order lines, in one database transaction.
+func (r *RefundService) claim(order *Order, input RefundInput) (*Transaction, *Transaction, map[uint64]int, error) {
+	var payment Transaction
+	var refund Transaction
+	lines := make(map[uint64]int)
+
+	err := r.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if input.onClaim != nil {
+			if err := input.onClaim(tx); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.First(&payment, order.TransactionID).Error; err != nil {
+			return errors.New("تراکنش پرداخت سفارش یافت نشد")
+		}
+		if payment.Status != TransactionStatusSucceed {
+			return errors.New("سفارش پرداخت نشده است و قابل بازپرداخت نیست")
+		}
+
+		var orderProducts []OrderProduct
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", order.ID).Order("id").Find(&orderProducts).Error; err != nil {
+			return err
+		}
+
+		if len(input.Lines) == 0 {
+			for _, orderProduct := range orderProducts {
+				if remaining := orderProduct.Quantity - orderProduct.RefundedQuantity; remaining > 0 {
+					lines[orderProduct.ID] = remaining
+				}
+			}
+		}
+		for _, line := range input.Lines {
+			lines[line.OrderProductID] += line.Quantity
+		}
+		if len(lines) == 0 {
+			return errors.New("محصولی برای بازپرداخت وجود ندارد")
+		}
+
+		claimed := make(map[uint64]OrderProduct, len(orderProducts))
+		for _, orderProduct := range orderProducts {
+			claimed[orderProduct.ID] = orderProduct
+		}
+
+		var amount utils.Money
+		for id, quantity := range lines {
+			orderProduct, ok := claimed[id]
+			if !ok {
+				return fmt.Errorf("محصول %v در این سفارش وجود ندارد", id)
+			}
+			if orderProduct.RefundedQuantity+quantity > orderProduct.Quantity {
+				return fmt.Errorf("تعداد بازپرداخت محصول %v بیشتر از تعداد خریداری شده است", orderProduct.ProductID)
+			}
+
+			amount += orderProduct.refundAmount(quantity)
+			if err := tx.Model(&orderProduct).Update("refunded_quantity", orderProduct.RefundedQuantity+quantity).Error; err != nil {
+				return err
+			}
+		}
+
+		var refunded struct {
+			Gateway utils.Money
+			Wallet  utils.Money
+		}
+		if err := tx.Model(&Transaction{}).
+			Where("parent_id = ? AND type = ? AND status IN ?", payment.ID, "refund", []TransactionStatus{TransactionStatusSucceed, TransactionStatusInProgress}).
+			Select("COALESCE(SUM(amount), 0) AS gateway, COALESCE(SUM(wallet_amount), 0) AS wallet").Scan(&refunded).Error; err != nil {
+			return err
+		}
+
+		// the last refund of an order pays back whatever is left of the
+		// order, shipping and rounding leftovers included
+		fullyRefunded := true
+		for _, orderProduct := range orderProducts {
+			fullyRefunded = fullyRefunded && orderProduct.RefundedQuantity+lines[orderProduct.ID] == orderProduct.Quantity
+		}
+		if fullyRefunded {
+			amount = order.TotalAmount - refunded.Gateway - refunded.Wallet
+		}
+
+		// the gateway can pay back at most what was paid through it, the
+		// rest goes to the wallet
+		gatewayAmount := min(amount, payment.Amount-refunded.Gateway)
+		if input.Method == RefundMethodWallet || gatewayAmount < 0 {
+			gatewayAmount = 0
+		}
+		if gatewayAmount != payment.Amount && !supportsPartialRefund(payment.Gateway) {
+			gatewayAmount = 0
+		}
+
+		gateway := payment.Gateway
+		if gatewayAmount == 0 {
+			gateway = walletGateway
+		}
+
+		description := input.Reason
+		refund = Transaction{
+			CustomerID:   order.CustomerID,
+			Device:       payment.Device,
+			Type:         "refund",
+			Gateway:      gateway,
+			Status:       TransactionStatusInProgress,
+			Amount:       gatewayAmount,
+			WalletAmount: amount - gatewayAmount,
+			ParentID:     &payment.ID,
+			Description:  &description,
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return err
+		}
+
+		for id, quantity := range lines {
+			if err := revokePurchasedGiftCards(tx, order.ID, claimed[id].ProductID, quantity, refund.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return &payment, &refund, lines, nil
+}
+
+// supportsPartialRefund reports whether the named gateway can pay back part
+// of a payment.
+func supportsPartialRefund(name string) bool {
+	gateway, err := utils.NewPaymentGateway(name)
+	if err != nil {
+		return false
+	}
+	refunder, ok := gateway.(utils.PartialRefunder)
+	return ok && refunder.SupportsPartialRefund()
+}
+
+// payBack sends the gateway part of a refund back to the customer's card.
+// The wallet part is credited once the refund is stored.
+func (r *RefundService) payBack(payment *Transaction, refund *Transaction, method RefundMethod) error {
+	if refund.Amount <= 0 {
+		return nil
+	}
+
+	gateway, err := utils.NewPaymentGateway(payment.Gateway)
+	if err != nil {
+		return err
+	}
+	if payment.Authority == nil {
+		return errors.New("شناسه پرداخت تراکنش یافت نشد")
+	}
+
+	if refund.Amount != payment.Amount && !supportsPartialRefund(payment.Gateway) {
+		return errors.New("درگاه پرداخت از بازپرداخت بخشی از مبلغ پشتیبانی نمی کند")
+	}
+
+	_, err = gateway.Refund(*payment.Authority, utils.ToGatewayAmount(gateway, refund.Amount))
+	return err
+}
+
+// unclaim gives the quantities claimed for a failed refund back to the
+// order lines and stores the failed refund transaction.
+func (r *RefundService) unclaim(refund *Transaction, lines map[uint64]int, input RefundInput) error {
+	return r.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if input.onUnclaim != nil {
+			if err := input.onUnclaim(tx); err != nil {
+				return err
+			}
+		}
+		for id, quantity := range lines {
+			if err := tx.Model(&OrderProduct{}).Where("id = ?", id).Update("refunded_quantity", gorm.Expr("refunded_quantity - ?", quantity)).Error; err != nil {
+				return err
+			}
+		}
+		if err := restoreRevokedGiftCards(tx, refund.ID); err != nil {
+			return err
+		}
+		return tx.Save(refund).Error
+	})
+}
+
+// complete stores the successful refund, restocks the refunded items if
+// asked to, takes back the loyalty points they earned and moves the order on
+// once every line is refunded.
+func (r *RefundService) complete(order *Order, payment *Transaction, refund *Transaction, lines map[uint64]int, input RefundInput) error {
+	return r.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		refund.Status = TransactionStatusSucceed
+		refund.ModifiedAt = &now
+		if err := tx.Save(refund).Error; err != nil {
+			return err
+		}
+		if input.onComplete != nil {
+			if err := input.onComplete(tx, refund); err != nil {
+				return err
+			}
+		}
+		if err := refundToWallet(tx, order, refund); err != nil {
+			return err
+		}
+
+		var orderProducts []OrderProduct
+		if err := tx.Where("order_id = ?", order.ID).Find(&orderProducts).Error; err != nil {
+			return err
+		}
+
+		fullyRefunded := true
+		for _, orderProduct := range orderProducts {
+			if quantity, ok := lines[orderProduct.ID]; ok && input.Restock {
+				if err := restock(tx, orderProduct.ProductID, orderProduct.VariantID, quantity); err != nil {
+					return err
+				}
+			}
+			fullyRefunded = fullyRefunded && orderProduct.RefundedQuantity == orderProduct.Quantity
+		}
+
+		if err := reverseLoyaltyPoints(tx, order, orderProducts, fullyRefunded); err != nil {
+			return err
+		}
+
+		if !fullyRefunded {
+			return nil
+		}
+
+		payment.Status = TransactionStatusRollBack
+		payment.ModifiedAt = &now
+		if err := tx.Save(payment).Error; err != nil {
+			return err
+		}
+
+		status := input.Status
+		if status == "" {
+			status = OrderStatusRefunded
+		}
+		if order.Status == status {
+			return nil
+		}
+		return changeOrderStatus(tx, order, status, input.ChangedBy, &input.Reason)
+	})
+}
+
+// GetByPayment returns the refunds made against a payment transaction.
+func (r *RefundService) GetByPayment(transactionId uint64) (*[]Transaction, error) {
+	var refunds []Transaction
+	err := r.repo.GetQuery().Where("parent_id = ? AND type = ?", transactionId, "refund").Order("id").Find(&refunds).Error
+	return &refunds, err
+}
+
+// Cancel cancels an order the shop has not confirmed yet. An unpaid order
+// simply gives its reserved stock back, a paid one is refunded in full. An
+// order whose payment is settled while it is being cancelled is refunded if
+// the payment went through.
+func (r *RefundService) Cancel(order *Order, reason, changedBy string) error {
+	if order.Status == OrderStatusWaitingForIPG {
+		err := r.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+			// locked like settleTx does, so a payment can't be settled and
+			// cancelled at the same time
+			var payment Transaction
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, order.TransactionID).Error; err != nil {
+				return err
+			}
+			if payment.Status != TransactionStatusNew && payment.Status != TransactionStatusInProgress {
+				return ErrPaymentSettled
+			}
+
+			now := time.Now()
+			failureCause := "order was cancelled"
+			payment.Status = TransactionStatusFailed
+			payment.FailureCause = &failureCause
+			payment.ModifiedAt = &now
+			if err := tx.Save(&payment).Error; err != nil {
+				return err
+			}
+
+			if err := changeOrderStatus(tx, order, OrderStatusCancelled, changedBy, &reason); err != nil {
+				return err
+			}
+			if err := releaseCouponRedemption(tx, order.ID); err != nil {
+				return err
+			}
+			if err := releaseWalletPayment(tx, order); err != nil {
+				return err
+			}
+			if err := releaseGiftCardRedemption(tx, order.ID); err != nil {
+				return err
+			}
+			if err := releaseLoyaltyRedemption(tx, order); err != nil {
+				return err
+			}
+			return releaseStockReservations(tx, order.ID)
+		})
+		if !errors.Is(err, ErrPaymentSettled) {
+			return err
+		}
+		if err := r.repo.GetQuery().Select("status").First(order, order.ID).Error; err != nil {
+			return err
+		}
+		if order.Status != OrderStatusNew {
+			return ErrPaymentSettled
+		}
+	}
+
+	if order.Status != OrderStatusNew {
+		return errors.New("سفارش تایید شده است و امکان لغو آن وجود ندارد")
+	}
+	_, err := r.Refund(order, RefundInput{
+		Method:    RefundMethodGateway,
+		Reason:    reason,
+		Restock:   true,
+		Status:    OrderStatusCancelled,
+		ChangedBy: changedBy,
+	})
+	return err
+}
@@ -0,0 +1,301 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReturnRequestStatus string
+
+const (
+	ReturnRequestStatusRequested ReturnRequestStatus = "requested"
+	ReturnRequestStatusApproved  ReturnRequestStatus = "approved"
+	ReturnRequestStatusRejected  ReturnRequestStatus = "rejected"
+	ReturnRequestStatusReceived  ReturnRequestStatus = "received"
+	// the refund is being paid back, the request goes back to received if
+	// it fails
+	ReturnRequestStatusRefunding ReturnRequestStatus = "refunding"
+	ReturnRequestStatusRefunded  ReturnRequestStatus = "refunded"
+)
+
+// returnRequestStatusTransitions lists the statuses a return request may move
+// to from each status. Refunding and refunded are only reached through
+// ReturnRequestService.Refund.
+var returnRequestStatusTransitions = map[ReturnRequestStatus][]ReturnRequestStatus{
+	ReturnRequestStatusRequested: {ReturnRequestStatusApproved, ReturnRequestStatusRejected},
+	ReturnRequestStatusApproved:  {ReturnRequestStatusReceived, ReturnRequestStatusRejected},
+	ReturnRequestStatusReceived:  {ReturnRequestStatusRefunding},
+	ReturnRequestStatusRefunding: {ReturnRequestStatusRefunded, ReturnRequestStatusReceived},
+}
+
+func (s ReturnRequestStatus) CanTransitionTo(next ReturnRequestStatus) bool {
+	for _, status := range returnRequestStatusTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+type ReturnResolution string
+
+const (
+	ReturnResolutionRefund   ReturnResolution = "refund"
+	ReturnResolutionExchange ReturnResolution = "exchange"
+)
+
+func (r ReturnResolution) IsValid() bool {
+	return r == ReturnResolutionRefund || r == ReturnResolutionExchange
+}
+
+type ReturnRequest struct {
+	ID                  uint64              `gorm:"primaryKey"`
+	OrderID             uint64              `gorm:"not null;index"`
+	CustomerID          uint64              `gorm:"not null;index"`
+	TransactionID       uint64              `gorm:"not null"`
+	RefundTransactionID *uint64             `gorm:"null"`
+	Status              ReturnRequestStatus `gorm:"not null;index"`
+	Resolution          ReturnResolution    `gorm:"not null"`
+	Reason              string              `gorm:"not null;type:text"`
+	RejectionReason     *string             `gorm:"null;type:text"`
+	ModifiedAt          *time.Time          `gorm:"type:timestamp with time zone"`
+	CreatedAt           time.Time           `gorm:"type:timestamp with time zone;default:now()"`
+
+	// Relations
+	Lines  []ReturnRequestLine  `gorm:"foreignKey:ReturnRequestID"`
+	Images []ReturnRequestImage `gorm:"foreignKey:ReturnRequestID"`
+}
+
+func (ReturnRequest) TableName() string {
+	return "return_request"
+}
+
+type ReturnRequestLine struct {
+	ID              uint64    `gorm:"primaryKey"`
+	ReturnRequestID uint64    `gorm:"not null;index"`
+	OrderProductID  uint64    `gorm:"not null;index"`
+	Quantity        int       `gorm:"not null"`
+	CreatedAt       time.Time `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (ReturnRequestLine) TableName() string {
+	return "return_request_line"
+}
+
+type ReturnRequestImage struct {
+	ID              uint64    `gorm:"primaryKey"`
+	ReturnRequestID uint64    `gorm:"not null;index"`
+	Image           string    `gorm:"not null"`
+	CreatedAt       time.Time `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (ReturnRequestImage) TableName() string {
+	return "return_request_image"
+}
+
+type ReturnRequestService struct {
+	repo          repository.Repository[ReturnRequest]
+	refundService *RefundService
+}
+
+func NewReturnRequestService(db *gorm.DB) *ReturnRequestService {
+	return &ReturnRequestService{
+		repo:          repository.NewGenericRepository[ReturnRequest](db),
+		refundService: NewRefundService(db),
+	}
+}
+
+// returnWindow is how long after delivery an order can be returned, read
+// from RETURN_WINDOW_DAYS and 7 days by default.
+func returnWindow() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("RETURN_WINDOW_DAYS"))
+	if err != nil || days < 1 {
+		days = 7
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Create opens a return request for lines of a delivered order. Every line
+// must belong to the order, and the returned quantity can't exceed what was
+// bought minus what is refunded or already asked back by another open request.
+func (r *ReturnRequestService) Create(order *Order, returnRequest *ReturnRequest) error {
+	return r.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := checkReturnable(tx, order, returnRequest, true); err != nil {
+			return err
+		}
+
+		returnRequest.OrderID = order.ID
+		returnRequest.CustomerID = order.CustomerID
+		returnRequest.TransactionID = order.TransactionID
+		returnRequest.Status = ReturnRequestStatusRequested
+		return tx.Create(returnRequest).Error
+	})
+}
+
+// Validate checks returnRequest can be made for order the way Create does,
+// without making it, so its images are only uploaded for requests that can
+// be made.
+func (r *ReturnRequestService) Validate(order *Order, returnRequest *ReturnRequest) error {
+	return checkReturnable(r.repo.GetQuery(), order, returnRequest, false)
+}
+
+// checkReturnable makes the checks Create describes, locking the lines of the
+// order when lock is set.
+func checkReturnable(tx *gorm.DB, order *Order, returnRequest *ReturnRequest, lock bool) error {
+	if order.Status != OrderStatusDelivered {
+		return errors.New("فقط سفارش های تحویل داده شده قابل مرجوع کردن هستند")
+	}
+	if len(returnRequest.Lines) == 0 {
+		return errors.New("محصولی برای مرجوع کردن انتخاب نشده است")
+	}
+
+	var delivered OrderStatusHistory
+	if err := tx.Where("order_id = ? AND to_status = ?", order.ID, OrderStatusDelivered).Order("id desc").First(&delivered).Error; err != nil {
+		return errors.New("تاریخ تحویل سفارش یافت نشد")
+	}
+	if time.Since(delivered.CreatedAt) > returnWindow() {
+		return errors.New("مهلت مرجوع کردن این سفارش به پایان رسیده است")
+	}
+
+	query := tx
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	var orderProducts []OrderProduct
+	if err := query.Where("order_id = ?", order.ID).Order("id").Find(&orderProducts).Error; err != nil {
+		return err
+	}
+
+	var pending []ReturnRequestLine
+	if err := tx.Joins("JOIN return_request ON return_request.id = return_request_line.return_request_id").
+		Where("return_request.order_id = ? AND return_request.status IN ?", order.ID, []ReturnRequestStatus{ReturnRequestStatusRequested, ReturnRequestStatusApproved, ReturnRequestStatusReceived}).
+		Find(&pending).Error; err != nil {
+		return err
+	}
+
+	available := make(map[uint64]int, len(orderProducts))
+	for _, orderProduct := range orderProducts {
+		available[orderProduct.ID] = orderProduct.Quantity - orderProduct.RefundedQuantity
+	}
+	for _, line := range pending {
+		available[line.OrderProductID] -= line.Quantity
+	}
+
+	for _, line := range returnRequest.Lines {
+		remaining, ok := available[line.OrderProductID]
+		if !ok {
+			return fmt.Errorf("محصول %v در این سفارش وجود ندارد", line.OrderProductID)
+		}
+		if line.Quantity < 1 || line.Quantity > remaining {
+			return fmt.Errorf("تعداد مرجوعی محصول %v بیشتر از تعداد قابل مرجوع است", line.OrderProductID)
+		}
+		available[line.OrderProductID] -= line.Quantity
+	}
+	return nil
+}
+
+func (r *ReturnRequestService) GetAll(customerId, orderId uint64, status ReturnRequestStatus, take, skip int) (*[]ReturnRequest, error) {
+	var returnRequests []ReturnRequest
+	query := r.repo.GetQuery()
+
+	if customerId > 0 {
+		query = query.Where("customer_id = ?", customerId)
+	}
+	if orderId > 0 {
+		query = query.Where("order_id = ?", orderId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	err := query.Order("id desc").Offset(skip).Limit(take).Preload("Lines").Preload("Images").Find(&returnRequests).Error
+	return &returnRequests, err
+}
+
+func (r *ReturnRequestService) GetById(id uint64) (*ReturnRequest, error) {
+	var returnRequest ReturnRequest
+	res := r.repo.GetQuery().Preload("Lines").Preload("Images").First(&returnRequest, id)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("درخواست مرجوعی یافت نشد")
+	}
+	return &returnRequest, res.Error
+}
+
+// ChangeStatus moves a return request along its status machine.
+func (r *ReturnRequestService) ChangeStatus(returnRequest *ReturnRequest, status ReturnRequestStatus, rejectionReason *string) error {
+	if status == ReturnRequestStatusRefunding || status == ReturnRequestStatusRefunded {
+		return errors.New("برای بازپرداخت درخواست مرجوعی از بازپرداخت استفاده کنید")
+	}
+	if returnRequest.Status == ReturnRequestStatusRefunding {
+		return errors.New("درخواست مرجوعی در حال بازپرداخت است")
+	}
+	if !returnRequest.Status.CanTransitionTo(status) {
+		return fmt.Errorf("تغییر وضعیت درخواست مرجوعی از %v به %v مجاز نیست", returnRequest.Status, status)
+	}
+	if status == ReturnRequestStatusRejected && rejectionReason == nil {
+		return errors.New("دلیل رد شدن درخواست مرجوعی را وارد کنید")
+	}
+
+	now := time.Now()
+	returnRequest.Status = status
+	returnRequest.RejectionReason = rejectionReason
+	returnRequest.ModifiedAt = &now
+
+	return r.repo.GetQuery().Omit(clause.Associations).Save(returnRequest).Error
+}
+
+// Refund pays back the lines of a received return request and restocks them
+// when asked to. The order moves to refunded once all of it is returned.
+//
+// The request is locked and moved to refunding along with the refund claim,
+// so it can't be refunded twice, and back to received if the refund fails.
+func (r *ReturnRequestService) Refund(returnRequest *ReturnRequest, order *Order, method RefundMethod, restock bool, changedBy string) (*Transaction, error) {
+	lines := make([]RefundLine, 0, len(returnRequest.Lines))
+	for _, line := range returnRequest.Lines {
+		lines = append(lines, RefundLine{OrderProductID: line.OrderProductID, Quantity: line.Quantity})
+	}
+
+	return r.refundService.Refund(order, RefundInput{
+		Lines:     lines,
+		Method:    method,
+		Reason:    fmt.Sprintf("مرجوعی شماره %v: %v", returnRequest.ID, returnRequest.Reason),
+		Restock:   restock,
+		ChangedBy: changedBy,
+		onClaim: func(tx *gorm.DB) error {
+			var current ReturnRequest
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "status").First(&current, returnRequest.ID).Error; err != nil {
+				return err
+			}
+			returnRequest.Status = current.Status
+			if returnRequest.Status == ReturnRequestStatusRefunding {
+				return errors.New("درخواست مرجوعی در حال بازپرداخت است")
+			}
+			if !returnRequest.Status.CanTransitionTo(ReturnRequestStatusRefunding) {
+				return errors.New("درخواست مرجوعی هنوز دریافت نشده است")
+			}
+			return setReturnRequestStatus(tx, returnRequest, ReturnRequestStatusRefunding)
+		},
+		onUnclaim: func(tx *gorm.DB) error {
+			return setReturnRequestStatus(tx, returnRequest, ReturnRequestStatusReceived)
+		},
+		onComplete: func(tx *gorm.DB, refund *Transaction) error {
+			returnRequest.RefundTransactionID = &refund.ID
+			return setReturnRequestStatus(tx, returnRequest, ReturnRequestStatusRefunded)
+		},
+	})
+}
+
+func setReturnRequestStatus(tx *gorm.DB, returnRequest *ReturnRequest, status ReturnRequestStatus) error {
+	now := time.Now()
+	returnRequest.Status = status
+	returnRequest.ModifiedAt = &now
+	return tx.Model(&ReturnRequest{}).Where("id = ?", returnRequest.ID).
+		Updates(map[string]any{"status": status, "refund_transaction_id": returnRequest.RefundTransactionID, "modified_at": now}).Error
+}
@@ -0,0 +1,255 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ShipmentStatus string
+
+const (
+	ShipmentStatusPreparing ShipmentStatus = "preparing"
+	ShipmentStatusShipped   ShipmentStatus = "shipped"
+	ShipmentStatusDelivered ShipmentStatus = "delivered"
+)
+
+var shipmentStatusTransitions = map[ShipmentStatus][]ShipmentStatus{
+	ShipmentStatusPreparing: {ShipmentStatusShipped},
+	ShipmentStatusShipped:   {ShipmentStatusDelivered},
+}
+
+func (s ShipmentStatus) CanTransitionTo(next ShipmentStatus) bool {
+	for _, status := range shipmentStatusTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ShipmentCarriers maps the carriers the shop ships with to the address a
+// package can be tracked at, %v is replaced with the tracking code.
+var ShipmentCarriers = map[string]string{
+	"post":    "https://tracking.post.ir/?id=%v",
+	"tipax":   "https://tipaxco.com/tracking?code=%v",
+	"chapar":  "https://chaparnet.com/track/%v",
+	"courier": "",
+}
+
+type Shipment struct {
+	ID           uint64         `gorm:"primaryKey"`
+	OrderID      uint64         `gorm:"not null;index"`
+	Carrier      string         `gorm:"not null"`
+	TrackingCode *string        `gorm:"null;index"`
+	TrackingURL  *string        `gorm:"null"`
+	Status       ShipmentStatus `gorm:"not null"`
+	ShippedAt    *time.Time     `gorm:"type:timestamp with time zone"`
+	DeliveredAt  *time.Time     `gorm:"type:timestamp with time zone"`
+	ModifiedAt   *time.Time     `gorm:"type:timestamp with time zone"`
+	CreatedAt    time.Time      `gorm:"type:timestamp with time zone;default:now()"`
+
+	// Relations
+	Items []ShipmentItem `gorm:"foreignKey:ShipmentID"`
+}
+
+func (Shipment) TableName() string {
+	return "shipment"
+}
+
+type ShipmentItem struct {
+	ID             uint64    `gorm:"primaryKey"`
+	ShipmentID     uint64    `gorm:"not null;index"`
+	OrderProductID uint64    `gorm:"not null;index"`
+	Quantity       int       `gorm:"not null"`
+	CreatedAt      time.Time `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (ShipmentItem) TableName() string {
+	return "shipment_item"
+}
+
+type ShipmentService struct {
+	repo repository.Repository[Shipment]
+}
+
+func NewShipmentService(db *gorm.DB) *ShipmentService {
+	return &ShipmentService{
+		repo: repository.NewGenericRepository[Shipment](db),
+	}
+}
+
+func (s *ShipmentService) GetByOrderId(orderId uint64) (*[]Shipment, error) {
+	var shipments []Shipment
+	err := s.repo.GetQuery().Where("order_id = ?", orderId).Order("id").Preload("Items").Find(&shipments).Error
+	return &shipments, err
+}
+
+func (s *ShipmentService) GetById(id uint64) (*Shipment, error) {
+	var shipment Shipment
+	res := s.repo.GetQuery().Preload("Items").First(&shipment, id)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("مرسوله یافت نشد")
+	}
+	return &shipment, res.Error
+}
+
+// Create packs lines of a confirmed order into a new shipment. A line can be
+// spread over several shipments, but never more of it than was bought and
+// not refunded.
+func (s *ShipmentService) Create(order *Order, shipment *Shipment) error {
+	if order.Status != OrderStatusConfirmed {
+		return errors.New("فقط سفارش های تایید شده قابل ارسال هستند")
+	}
+	if _, ok := ShipmentCarriers[shipment.Carrier]; !ok {
+		return fmt.Errorf("شرکت حمل %v تعریف نشده است", shipment.Carrier)
+	}
+	if len(shipment.Items) == 0 {
+		return errors.New("محصولی برای ارسال انتخاب نشده است")
+	}
+
+	return s.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		var orderProducts []OrderProduct
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", order.ID).Order("id").Find(&orderProducts).Error; err != nil {
+			return err
+		}
+
+		unshipped, err := unshippedQuantities(tx, order.ID, orderProducts)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range shipment.Items {
+			remaining, ok := unshipped[item.OrderProductID]
+			if !ok {
+				return fmt.Errorf("محصول %v در این سفارش وجود ندارد", item.OrderProductID)
+			}
+			if item.Quantity < 1 || item.Quantity > remaining {
+				return fmt.Errorf("تعداد ارسالی محصول %v بیشتر از تعداد ارسال نشده است", item.OrderProductID)
+			}
+			unshipped[item.OrderProductID] -= item.Quantity
+		}
+
+		shipment.OrderID = order.ID
+		shipment.Status = ShipmentStatusPreparing
+		shipment.TrackingURL = trackingURL(shipment.Carrier, shipment.TrackingCode)
+		return tx.Create(shipment).Error
+	})
+}
+
+// ChangeStatus ships or delivers a shipment. The order is marked shipped once
+// every line is in a shipped shipment, and delivered once all of them arrived.
+func (s *ShipmentService) ChangeStatus(shipment *Shipment, status ShipmentStatus, trackingCode *string, changedBy string) error {
+	if !shipment.Status.CanTransitionTo(status) {
+		return fmt.Errorf("تغییر وضعیت مرسوله از %v به %v مجاز نیست", shipment.Status, status)
+	}
+	if trackingCode != nil {
+		shipment.TrackingCode = trackingCode
+		shipment.TrackingURL = trackingURL(shipment.Carrier, trackingCode)
+	}
+	if status == ShipmentStatusShipped && shipment.TrackingCode == nil && shipment.Carrier != "courier" {
+		return errors.New("کد رهگیری مرسوله را وارد کنید")
+	}
+
+	return s.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		var order Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, shipment.OrderID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		shipment.Status = status
+		shipment.ModifiedAt = &now
+		if status == ShipmentStatusShipped {
+			shipment.ShippedAt = &now
+		} else {
+			shipment.DeliveredAt = &now
+		}
+		if err := tx.Omit(clause.Associations).Save(shipment).Error; err != nil {
+			return err
+		}
+
+		return syncOrderFulfillment(tx, &order, changedBy)
+	})
+}
+
+// syncOrderFulfillment moves the order to shipped or delivered when its
+// shipments cover all of its lines that are not refunded.
+func syncOrderFulfillment(tx *gorm.DB, order *Order, changedBy string) error {
+	var orderProducts []OrderProduct
+	if err := tx.Where("order_id = ?", order.ID).Find(&orderProducts).Error; err != nil {
+		return err
+	}
+
+	var shipments []Shipment
+	if err := tx.Where("order_id = ?", order.ID).Preload("Items").Find(&shipments).Error; err != nil {
+		return err
+	}
+
+	notShipped := make(map[uint64]int, len(orderProducts))
+	notDelivered := make(map[uint64]int, len(orderProducts))
+	for _, orderProduct := range orderProducts {
+		notShipped[orderProduct.ID] = orderProduct.Quantity - orderProduct.RefundedQuantity
+		notDelivered[orderProduct.ID] = orderProduct.Quantity - orderProduct.RefundedQuantity
+	}
+	for _, shipment := range shipments {
+		for _, item := range shipment.Items {
+			if shipment.Status == ShipmentStatusShipped || shipment.Status == ShipmentStatusDelivered {
+				notShipped[item.OrderProductID] -= item.Quantity
+			}
+			if shipment.Status == ShipmentStatusDelivered {
+				notDelivered[item.OrderProductID] -= item.Quantity
+			}
+		}
+	}
+
+	allShipped, allDelivered := true, true
+	for id := range notShipped {
+		allShipped = allShipped && notShipped[id] <= 0
+		allDelivered = allDelivered && notDelivered[id] <= 0
+	}
+
+	if allShipped && order.Status == OrderStatusConfirmed {
+		reason := "all items are shipped"
+		if err := changeOrderStatus(tx, order, OrderStatusShipped, changedBy, &reason); err != nil {
+			return err
+		}
+	}
+	if allDelivered && order.Status == OrderStatusShipped {
+		reason := "all items are delivered"
+		return changeOrderStatus(tx, order, OrderStatusDelivered, changedBy, &reason)
+	}
+	return nil
+}
+
+// unshippedQuantities returns how many of each order line is not in any
+// shipment yet.
+func unshippedQuantities(tx *gorm.DB, orderId uint64, orderProducts []OrderProduct) (map[uint64]int, error) {
+	var items []ShipmentItem
+	if err := tx.Joins("JOIN shipment ON shipment.id = shipment_item.shipment_id").Where("shipment.order_id = ?", orderId).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	unshipped := make(map[uint64]int, len(orderProducts))
+	for _, orderProduct := range orderProducts {
+		unshipped[orderProduct.ID] = orderProduct.Quantity - orderProduct.RefundedQuantity
+	}
+	for _, item := range items {
+		unshipped[item.OrderProductID] -= item.Quantity
+	}
+	return unshipped, nil
+}
+
+func trackingURL(carrier string, trackingCode *string) *string {
+	format := ShipmentCarriers[carrier]
+	if format == "" || trackingCode == nil || strings.TrimSpace(*trackingCode) == "" {
+		return nil
+	}
+	url := fmt.Sprintf(format, *trackingCode)
+	return &url
+}
@@ -0,0 +1,303 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ShippingZoneCapital = "capital"
+	ShippingZoneCentral = "central"
+	ShippingZoneRemote  = "remote"
+)
+
+// ShippingZones groups the provinces into the zones shipping rates are
+// priced for.
+var ShippingZones = map[string]string{
+	"تهران":               ShippingZoneCapital,
+	"البرز":               ShippingZoneCapital,
+	"قم":                  ShippingZoneCentral,
+	"مرکزی":               ShippingZoneCentral,
+	"قزوین":               ShippingZoneCentral,
+	"سمنان":               ShippingZoneCentral,
+	"اصفهان":              ShippingZoneCentral,
+	"زنجان":               ShippingZoneCentral,
+	"مازندران":            ShippingZoneCentral,
+	"گیلان":               ShippingZoneCentral,
+	"همدان":               ShippingZoneCentral,
+	"یزد":                 ShippingZoneCentral,
+	"فارس":                ShippingZoneCentral,
+	"آذربایجان شرقی":      ShippingZoneCentral,
+	"خراسان رضوی":         ShippingZoneCentral,
+	"گلستان":              ShippingZoneCentral,
+	"آذربایجان غربی":      ShippingZoneRemote,
+	"اردبیل":              ShippingZoneRemote,
+	"ایلام":               ShippingZoneRemote,
+	"بوشهر":               ShippingZoneRemote,
+	"چهارمحال و بختیاری":  ShippingZoneRemote,
+	"خراسان جنوبی":        ShippingZoneRemote,
+	"خراسان شمالی":        ShippingZoneRemote,
+	"خوزستان":             ShippingZoneRemote,
+	"سیستان و بلوچستان":   ShippingZoneRemote,
+	"کردستان":             ShippingZoneRemote,
+	"کرمان":               ShippingZoneRemote,
+	"کرمانشاه":            ShippingZoneRemote,
+	"کهگیلویه و بویراحمد": ShippingZoneRemote,
+	"لرستان":              ShippingZoneRemote,
+	"هرمزگان":             ShippingZoneRemote,
+}
+
+// ShippingZone returns the zone province belongs to. Unknown provinces are
+// treated as remote.
+func ShippingZone(province string) string {
+	if zone, ok := ShippingZones[province]; ok {
+		return zone
+	}
+	return ShippingZoneRemote
+}
+
+// ShippingParcel is what a shipping cost is calculated for.
+type ShippingParcel struct {
+	Weight       float64
+	Subtotal     utils.Money
+	Province     string
+	FreeShipping bool
+}
+
+// ShippingCalculator prices a parcel for a delivery method.
+type ShippingCalculator interface {
+	Quote(method *ShippingMethod, parcel ShippingParcel) (utils.Money, error)
+}
+
+var shippingCalculators = map[string]ShippingCalculator{
+	"weight": weightShippingCalculator{},
+	"pickup": pickupShippingCalculator{},
+}
+
+// RegisterShippingCalculator makes a calculator available to delivery methods
+// under name.
+func RegisterShippingCalculator(name string, calculator ShippingCalculator) {
+	shippingCalculators[name] = calculator
+}
+
+// weightShippingCalculator charges the price of the rate whose weight bracket
+// the parcel falls in, preferring rates of the parcel's zone over rates
+// without a zone.
+type weightShippingCalculator struct{}
+
+func (weightShippingCalculator) Quote(method *ShippingMethod, parcel ShippingParcel) (utils.Money, error) {
+	zone := ShippingZone(parcel.Province)
+
+	var match *ShippingRate
+	for i, rate := range method.Rates {
+		if rate.Zone != "" && rate.Zone != zone {
+			continue
+		}
+		if parcel.Weight < rate.MinWeight || (rate.MaxWeight != nil && parcel.Weight >= *rate.MaxWeight) {
+			continue
+		}
+		if match == nil || (match.Zone == "" && rate.Zone != "") {
+			match = &method.Rates[i]
+		}
+	}
+
+	if match == nil {
+		return 0, fmt.Errorf("ارسال با %v برای این وزن و مقصد امکان پذیر نیست", method.Name)
+	}
+	return match.Price, nil
+}
+
+// pickupShippingCalculator is for orders collected at the store.
+type pickupShippingCalculator struct{}
+
+func (pickupShippingCalculator) Quote(method *ShippingMethod, parcel ShippingParcel) (utils.Money, error) {
+	return 0, nil
+}
+
+type ShippingMethod struct {
+	ID         uint64 `gorm:"primaryKey"`
+	Code       string `gorm:"not null;unique"`
+	Name       string `gorm:"not null"`
+	Calculator string `gorm:"not null"`
+	// orders worth at least this much ship for free
+	FreeShippingThreshold *utils.Money `gorm:"null"`
+	IsActive              *bool        `gorm:"default:true"`
+	ModifiedAt            *time.Time   `gorm:"type:timestamp with time zone"`
+	CreatedAt             time.Time    `gorm:"type:timestamp with time zone;default:now()"`
+
+	// Relations
+	Rates []ShippingRate `gorm:"foreignKey:ShippingMethodID"`
+}
+
+func (ShippingMethod) TableName() string {
+	return "shipping_method"
+}
+
+type ShippingRate struct {
+	ID               uint64 `gorm:"primaryKey"`
+	ShippingMethodID uint64 `gorm:"not null;index"`
+	// an empty zone applies to every zone without a rate of its own
+	Zone       string      `gorm:"not null;default:''"`
+	MinWeight  float64     `gorm:"not null;default:0"`
+	MaxWeight  *float64    `gorm:"null"`
+	Price      utils.Money `gorm:"not null"`
+	ModifiedAt *time.Time  `gorm:"type:timestamp with time zone"`
+	CreatedAt  time.Time   `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (ShippingRate) TableName() string {
+	return "shipping_rate"
+}
+
+// ShippingQuote is the cost of sending a parcel with a delivery method.
+type ShippingQuote struct {
+	Code  string
+	Name  string
+	Cost  utils.Money
+	Error string `json:",omitempty"`
+}
+
+type ShippingService struct {
+	repo repository.Repository[ShippingMethod]
+}
+
+func NewShippingService(db *gorm.DB) *ShippingService {
+	return &ShippingService{
+		repo: repository.NewGenericRepository[ShippingMethod](db),
+	}
+}
+
+func (s *ShippingService) GetAll(onlyActive bool) (*[]ShippingMethod, error) {
+	var methods []ShippingMethod
+	query := s.repo.GetQuery()
+	if onlyActive {
+		query = query.Where("is_active = ?", true)
+	}
+	err := query.Order("id").Preload("Rates", func(db *gorm.DB) *gorm.DB {
+		return db.Order("zone, min_weight")
+	}).Find(&methods).Error
+	return &methods, err
+}
+
+func (s *ShippingService) GetById(id uint64) (*ShippingMethod, error) {
+	var method ShippingMethod
+	res := s.repo.GetQuery().Preload("Rates").First(&method, id)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("روش ارسال یافت نشد")
+	}
+	return &method, res.Error
+}
+
+func (s *ShippingService) Create(method *ShippingMethod) error {
+	if _, ok := shippingCalculators[method.Calculator]; !ok {
+		return fmt.Errorf("محاسبه گر هزینه ارسال %v تعریف نشده است", method.Calculator)
+	}
+	return s.repo.Create(method)
+}
+
+func (s *ShippingService) Update(method *ShippingMethod) error {
+	if _, ok := shippingCalculators[method.Calculator]; !ok {
+		return fmt.Errorf("محاسبه گر هزینه ارسال %v تعریف نشده است", method.Calculator)
+	}
+	return s.repo.GetQuery().Omit(clause.Associations).Save(method).Error
+}
+
+// SetRates replaces every rate of a delivery method.
+func (s *ShippingService) SetRates(method *ShippingMethod, rates []ShippingRate) error {
+	return s.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("shipping_method_id = ?", method.ID).Delete(&ShippingRate{}).Error; err != nil {
+			return err
+		}
+		for i := range rates {
+			rates[i].ShippingMethodID = method.ID
+		}
+		if len(rates) > 0 {
+			if err := tx.Create(&rates).Error; err != nil {
+				return err
+			}
+		}
+		method.Rates = rates
+		return nil
+	})
+}
+
+// Quote prices parcel with every active delivery method. Methods that can't
+// deliver it are still listed, with the reason in Error.
+func (s *ShippingService) Quote(parcel ShippingParcel) (*[]ShippingQuote, error) {
+	methods, err := s.GetAll(true)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := make([]ShippingQuote, 0, len(*methods))
+	for i := range *methods {
+		method := &(*methods)[i]
+		quote := ShippingQuote{Code: method.Code, Name: method.Name}
+		cost, err := quoteShipping(method, parcel)
+		if err != nil {
+			quote.Error = err.Error()
+		}
+		quote.Cost = cost
+		quotes = append(quotes, quote)
+	}
+
+	return &quotes, nil
+}
+
+// QuoteCart prices the customer's cart sent to province.
+func (s *ShippingService) QuoteCart(customerId uint64, province string) (*ShippingParcel, *[]ShippingQuote, error) {
+	tx := s.repo.GetQuery()
+
+	cart, lines, _, err := loadCartLines(tx, customerId, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pricing, err := priceCart(tx, cart, lines)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parcel := ShippingParcel{
+		Weight:       pricing.Weight,
+		Subtotal:     pricing.Total,
+		Province:     province,
+		FreeShipping: pricing.FreeShipping != nil,
+	}
+
+	quotes, err := s.Quote(parcel)
+	return &parcel, quotes, err
+}
+
+// quoteShippingTx loads the active delivery method code and prices parcel with it.
+func quoteShippingTx(tx *gorm.DB, code string, parcel ShippingParcel) (*ShippingMethod, utils.Money, error) {
+	var method ShippingMethod
+	if err := tx.Where("code = ? AND is_active = ?", code, true).Preload("Rates").First(&method).Error; err != nil {
+		return nil, 0, fmt.Errorf("روش ارسال %v نامعتبر است", code)
+	}
+
+	cost, err := quoteShipping(&method, parcel)
+	return &method, cost, err
+}
+
+func quoteShipping(method *ShippingMethod, parcel ShippingParcel) (utils.Money, error) {
+	calculator, ok := shippingCalculators[method.Calculator]
+	if !ok {
+		return 0, fmt.Errorf("محاسبه گر هزینه ارسال %v تعریف نشده است", method.Calculator)
+	}
+
+	cost, err := calculator.Quote(method, parcel)
+	if err != nil {
+		return 0, err
+	}
+	if parcel.FreeShipping || (method.FreeShippingThreshold != nil && parcel.Subtotal >= *method.FreeShippingThreshold) {
+		return 0, nil
+	}
+	return cost, nil
+}
@@ -5,16 +5,21 @@ import (
 
 	"github.com/Hello256World/shop-api/repository"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Specification struct {
-	ID         uint64     `gorm:"primaryKey"`
-	Key        string     `gorm:"not null"`
-	Value      string     `gorm:"not null"`
-	ProductID  uint64     `gorm:"not null"`
-	IsActive   *bool      `gorm:"default:true"`
-	ModifiedAt *time.Time `gorm:"type:timestamp with time zone"`
-	CreatedAt  time.Time  `gorm:"type:timestamp with time zone;default:now()"`
+	ID          uint64     `gorm:"primaryKey"`
+	Key         string     `gorm:"not null"`
+	Value       string     `gorm:"not null"`
+	ProductID   uint64     `gorm:"not null"`
+	AttributeID *uint64    `gorm:"null;index"`
+	IsActive    *bool      `gorm:"default:true"`
+	ModifiedAt  *time.Time `gorm:"type:timestamp with time zone"`
+	CreatedAt   time.Time  `gorm:"type:timestamp with time zone;default:now()"`
+
+	// Relations
+	Attribute *SpecificationAttribute `gorm:"foreignKey:AttributeID" json:",omitempty"`
 }
 
 func (Specification) TableName() string {
@@ -33,12 +38,22 @@ func NewSpecificationService(db *gorm.DB) *SpecificationService {
 
 func (s *SpecificationService) GetAll(id uint64) (*[]Specification, error) {
 	var entities []Specification
-	res := s.repo.GetQuery().Where("product_id = ?", id).Find(&entities)
+	res := s.repo.GetQuery().Joins("LEFT JOIN specification_attribute ON specification_attribute.id = specification.attribute_id").
+		Where("specification.product_id = ?", id).Order("specification_attribute.position NULLS LAST, specification.id").
+		Preload("Attribute").Find(&entities)
 	return &entities, res.Error
 }
 
 func (s *SpecificationService) Create(entity Specification) error {
-	return s.repo.Create(&entity)
+	return s.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := applySpecificationSchema(tx, &entity); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Create(&entity).Error; err != nil {
+			return err
+		}
+		return refreshSearchIndex(tx, entity.ProductID)
+	})
 }
 
 func (s *SpecificationService) GetById(id uint64) (*Specification, error) {
@@ -46,9 +61,26 @@ func (s *SpecificationService) GetById(id uint64) (*Specification, error) {
 }
 
 func (s *SpecificationService) Update(entity *Specification) error {
-	return s.repo.Update(entity)
+	return s.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := applySpecificationSchema(tx, entity); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(entity).Error; err != nil {
+			return err
+		}
+		return refreshSearchIndex(tx, entity.ProductID)
+	})
 }
 
 func (s *SpecificationService) Delete(id uint64)error {
-	return s.repo.Delete(id)
+	return s.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		var entity Specification
+		if err := tx.First(&entity, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&entity).Error; err != nil {
+			return err
+		}
+		return refreshSearchIndex(tx, entity.ProductID)
+	})
 }
\ No newline at end of file
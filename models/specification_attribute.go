@@ -0,0 +1,392 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SpecificationAttributeType string
+
+const (
+	SpecificationAttributeText    SpecificationAttributeType = "text"
+	SpecificationAttributeNumber  SpecificationAttributeType = "number"
+	SpecificationAttributeBoolean SpecificationAttributeType = "boolean"
+	// the value is one of the attribute's Values
+	SpecificationAttributeSelect SpecificationAttributeType = "select"
+)
+
+var SpecificationAttributeTypes = []SpecificationAttributeType{SpecificationAttributeText, SpecificationAttributeNumber, SpecificationAttributeBoolean, SpecificationAttributeSelect}
+
+// the values boolean attributes are stored with
+const (
+	specificationYes = "بله"
+	specificationNo  = "خیر"
+)
+
+// SpecificationAttribute defines a specification the products of a category,
+// and of its subcategories, have. Once a category has attributes, products in
+// it can only have specifications of those keys, with values of their type.
+// Numbers are kept without Unit, which is shown next to them, and Position
+// orders the specifications of a product.
+type SpecificationAttribute struct {
+	ID         uint64                     `gorm:"primaryKey"`
+	CategoryID uint64                     `gorm:"not null;uniqueIndex:idx_specification_attribute_key"`
+	Key        string                     `gorm:"not null;uniqueIndex:idx_specification_attribute_key"`
+	Type       SpecificationAttributeType `gorm:"not null;default:text"`
+	Unit       *string                    `gorm:"null"`
+	Position   int                        `gorm:"not null;default:0"`
+	ModifiedAt *time.Time                 `gorm:"type:timestamp with time zone"`
+	CreatedAt  time.Time                  `gorm:"type:timestamp with time zone;default:now()"`
+
+	// Relations
+	Values []SpecificationAttributeValue `gorm:"foreignKey:AttributeID;constraint:OnDelete:CASCADE;"`
+}
+
+func (SpecificationAttribute) TableName() string {
+	return "specification_attribute"
+}
+
+// SpecificationAttributeValue is a value a select attribute allows.
+type SpecificationAttributeValue struct {
+	ID          uint64 `gorm:"primaryKey"`
+	AttributeID uint64 `gorm:"not null;index"`
+	Value       string `gorm:"not null"`
+	Position    int    `gorm:"not null;default:0"`
+}
+
+func (SpecificationAttributeValue) TableName() string {
+	return "specification_attribute_value"
+}
+
+// UnmatchedSpecification is a specification Backfill could not link to an
+// attribute, with the reason why.
+type UnmatchedSpecification struct {
+	Specification
+	Reason string
+}
+
+type SpecificationAttributeService struct {
+	repo repository.Repository[SpecificationAttribute]
+}
+
+func NewSpecificationAttributeService(db *gorm.DB) *SpecificationAttributeService {
+	return &SpecificationAttributeService{
+		repo: repository.NewGenericRepository[SpecificationAttribute](db),
+	}
+}
+
+// GetAll returns the attributes products of the category have, its own and
+// those of its parents.
+func (s *SpecificationAttributeService) GetAll(categoryId uint64) (*[]SpecificationAttribute, error) {
+	attributes, err := categoryAttributes(s.repo.GetQuery(), categoryId)
+	return &attributes, err
+}
+
+func (s *SpecificationAttributeService) GetById(id uint64) (*SpecificationAttribute, error) {
+	var attribute SpecificationAttribute
+	res := s.repo.GetQuery().Preload("Values", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).First(&attribute, id)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("ویژگی مورد نظر یافت نشد")
+	}
+	return &attribute, res.Error
+}
+
+// Save creates or updates an attribute along with the values it allows,
+// which take the place of the values it allowed before. Its key can't be the
+// key of an attribute of the category's parents or subcategories, since
+// their products would have both.
+func (s *SpecificationAttributeService) Save(attribute *SpecificationAttribute, values []string) error {
+	if !slices.Contains(SpecificationAttributeTypes, attribute.Type) {
+		return fmt.Errorf("نوع ویژگی %v نامعتبر است", attribute.Type)
+	}
+	if attribute.Type == SpecificationAttributeSelect && len(values) == 0 {
+		return errors.New("برای ویژگی انتخابی مقادیر مجاز را وارد کنید")
+	}
+	if attribute.Type != SpecificationAttributeSelect && len(values) > 0 {
+		return errors.New("مقادیر مجاز فقط برای ویژگی انتخابی قابل تعریف است")
+	}
+	attribute.Key = strings.Join(strings.Fields(attribute.Key), " ")
+
+	return s.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		attributes, err := categoryAttributes(tx, attribute.CategoryID)
+		if err != nil {
+			return err
+		}
+		descendants, err := categoryDescendants(tx, attribute.CategoryID)
+		if err != nil {
+			return err
+		}
+		if len(descendants) > 0 {
+			var below []SpecificationAttribute
+			if err := tx.Where("category_id IN ?", descendants).Find(&below).Error; err != nil {
+				return err
+			}
+			attributes = append(attributes, below...)
+		}
+		for _, other := range attributes {
+			if other.ID != attribute.ID && specificationKey(other.Key) == specificationKey(attribute.Key) {
+				return fmt.Errorf("ویژگی %v قبلا برای این دسته بندی تعریف شده است", other.Key)
+			}
+		}
+
+		if err := tx.Omit(clause.Associations).Save(attribute).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("attribute_id = ?", attribute.ID).Delete(&SpecificationAttributeValue{}).Error; err != nil {
+			return err
+		}
+		attribute.Values = nil
+		for i, value := range values {
+			attribute.Values = append(attribute.Values, SpecificationAttributeValue{AttributeID: attribute.ID, Value: strings.TrimSpace(value), Position: i})
+		}
+		if len(attribute.Values) > 0 {
+			return tx.Create(&attribute.Values).Error
+		}
+		return nil
+	})
+}
+
+// Delete removes an attribute. The specifications of it are kept as free
+// key and value pairs.
+func (s *SpecificationAttributeService) Delete(id uint64) error {
+	return s.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Specification{}).Where("attribute_id = ?", id).Update("attribute_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("attribute_id = ?", id).Delete(&SpecificationAttributeValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&SpecificationAttribute{}, id).Error
+	})
+}
+
+// Backfill links the specifications of the products of a category and its
+// subcategories, written before the category had attributes, to the
+// attribute of their key, writing them the way the attribute does. It
+// returns the specifications that have no attribute or a value the attribute
+// doesn't allow, which are left as they were.
+func (s *SpecificationAttributeService) Backfill(categoryId uint64) (*[]UnmatchedSpecification, error) {
+	unmatched := []UnmatchedSpecification{}
+
+	err := s.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		descendants, err := categoryDescendants(tx, categoryId)
+		if err != nil {
+			return err
+		}
+
+		var products []Product
+		if err := tx.Select("id", "category_id").Where("category_id IN ?", append(descendants, categoryId)).Find(&products).Error; err != nil {
+			return err
+		}
+
+		schemas := make(map[uint64][]SpecificationAttribute)
+		for _, product := range products {
+			attributes, ok := schemas[product.CategoryID]
+			if !ok {
+				if attributes, err = categoryAttributes(tx, product.CategoryID); err != nil {
+					return err
+				}
+				schemas[product.CategoryID] = attributes
+			}
+			if len(attributes) == 0 {
+				continue
+			}
+
+			var specifications []Specification
+			if err := tx.Where("product_id = ?", product.ID).Order("id").Find(&specifications).Error; err != nil {
+				return err
+			}
+
+			linked := make(map[uint64]bool)
+			for _, specification := range specifications {
+				if specification.AttributeID != nil {
+					linked[*specification.AttributeID] = true
+				}
+			}
+
+			for _, specification := range specifications {
+				if specification.AttributeID != nil {
+					continue
+				}
+
+				var attribute *SpecificationAttribute
+				for i := range attributes {
+					if specificationKey(attributes[i].Key) == specificationKey(specification.Key) {
+						attribute = &attributes[i]
+					}
+				}
+				if attribute == nil {
+					unmatched = append(unmatched, UnmatchedSpecification{specification, fmt.Sprintf("مشخصه %v برای دسته بندی این محصول تعریف نشده است", specification.Key)})
+					continue
+				}
+				if linked[attribute.ID] {
+					unmatched = append(unmatched, UnmatchedSpecification{specification, fmt.Sprintf("مشخصه %v قبلا برای این محصول ثبت شده است", attribute.Key)})
+					continue
+				}
+				value, err := attribute.parse(specification.Value)
+				if err != nil {
+					unmatched = append(unmatched, UnmatchedSpecification{specification, err.Error()})
+					continue
+				}
+
+				if err := tx.Model(&specification).Updates(map[string]any{"attribute_id": attribute.ID, "key": attribute.Key, "value": value, "modified_at": time.Now()}).Error; err != nil {
+					return err
+				}
+				linked[attribute.ID] = true
+			}
+		}
+		return nil
+	})
+	return &unmatched, err
+}
+
+// categoryAttributes returns the attributes of a category and its parents,
+// in display order.
+func categoryAttributes(tx *gorm.DB, categoryId uint64) ([]SpecificationAttribute, error) {
+	var categoryIds []uint64
+	// the depth guards against a category that is its own ancestor
+	for depth := 0; depth < 32; depth++ {
+		var category Category
+		if err := tx.Select("id", "parent_id").First(&category, categoryId).Error; err != nil {
+			return nil, err
+		}
+		categoryIds = append(categoryIds, category.ID)
+		if category.ParentID == nil {
+			break
+		}
+		categoryId = *category.ParentID
+	}
+
+	var attributes []SpecificationAttribute
+	err := tx.Where("category_id IN ?", categoryIds).Order("position, id").
+		Preload("Values", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Find(&attributes).Error
+	return attributes, err
+}
+
+// categoryDescendants returns the ids of the subcategories of a category, and
+// of theirs, all the way down.
+func categoryDescendants(tx *gorm.DB, categoryId uint64) ([]uint64, error) {
+	parents, err := categoryParents(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var descendants []uint64
+	for id := range parents {
+		// the depth guards against a category that is its own ancestor
+		parent := parents[id]
+		for depth := 0; parent != nil && depth <= len(parents); depth++ {
+			if *parent == categoryId {
+				if id != categoryId {
+					descendants = append(descendants, id)
+				}
+				break
+			}
+			parent = parents[*parent]
+		}
+	}
+	return descendants, nil
+}
+
+// specificationKey is what keys are compared by, so "RAM", "Ram" and " ram"
+// are the same key.
+func specificationKey(key string) string {
+	return strings.ToLower(strings.Join(strings.Fields(utils.NormalizePersian(key)), " "))
+}
+
+// applySpecificationSchema checks specification against the attributes of its
+// product's category and writes its key and value the way the attribute
+// defines them. Specifications of products in categories without attributes
+// are left as they are.
+func applySpecificationSchema(tx *gorm.DB, specification *Specification) error {
+	var product Product
+	if err := tx.Select("id", "category_id").First(&product, specification.ProductID).Error; err != nil {
+		return err
+	}
+	attributes, err := categoryAttributes(tx, product.CategoryID)
+	if err != nil {
+		return err
+	}
+	if len(attributes) == 0 {
+		specification.AttributeID = nil
+		return nil
+	}
+
+	var attribute *SpecificationAttribute
+	for i := range attributes {
+		if specificationKey(attributes[i].Key) == specificationKey(specification.Key) {
+			attribute = &attributes[i]
+		}
+	}
+	if attribute == nil {
+		return fmt.Errorf("مشخصه %v برای دسته بندی این محصول تعریف نشده است", specification.Key)
+	}
+
+	var count int64
+	if err := tx.Model(&Specification{}).Where("product_id = ? AND attribute_id = ? AND id <> ?", specification.ProductID, attribute.ID, specification.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("مشخصه %v قبلا برای این محصول ثبت شده است", attribute.Key)
+	}
+
+	value, err := attribute.parse(specification.Value)
+	if err != nil {
+		return err
+	}
+
+	specification.AttributeID = &attribute.ID
+	specification.Key = attribute.Key
+	specification.Value = value
+	return nil
+}
+
+// parse checks value is of the attribute's type and returns it the way it is
+// stored.
+func (a *SpecificationAttribute) parse(value string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	switch a.Type {
+	case SpecificationAttributeNumber:
+		number := strings.ReplaceAll(utils.NormalizePersian(value), "٫", ".")
+		if a.Unit != nil {
+			number = strings.TrimSpace(strings.TrimSuffix(number, *a.Unit))
+		}
+		parsed, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return "", fmt.Errorf("مقدار %v باید عدد باشد", a.Key)
+		}
+		return strconv.FormatFloat(parsed, 'f', -1, 64), nil
+	case SpecificationAttributeBoolean:
+		switch strings.ToLower(utils.NormalizePersian(value)) {
+		case "true", "1", specificationYes, "دارد":
+			return specificationYes, nil
+		case "false", "0", specificationNo, "ندارد":
+			return specificationNo, nil
+		}
+		return "", fmt.Errorf("مقدار %v باید %v یا %v باشد", a.Key, specificationYes, specificationNo)
+	case SpecificationAttributeSelect:
+		for _, allowed := range a.Values {
+			if specificationKey(allowed.Value) == specificationKey(value) {
+				return allowed.Value, nil
+			}
+		}
+		return "", fmt.Errorf("مقدار %v برای %v مجاز نیست", value, a.Key)
+	}
+
+	if value == "" {
+		return "", fmt.Errorf("مقدار %v را وارد کنید", a.Key)
+	}
+	return value, nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import "testing"
+
+func TestSpecificationAttributeParse(t *testing.T) {
+	gb := "GB"
+	attributes := []struct {
+		attribute SpecificationAttribute
+		accepted  map[string]string
+		rejected  []string
+	}{
+		{
+			attribute: SpecificationAttribute{Key: "وزن", Type: SpecificationAttributeNumber},
+			accepted:  map[string]string{"1.50": "1.5", "۱٫۵": "1.5", "١٢": "12"},
+			rejected:  []string{"سبک", ""},
+		},
+		{
+			attribute: SpecificationAttribute{Key: "RAM", Type: SpecificationAttributeNumber, Unit: &gb},
+			accepted:  map[string]string{"16 GB": "16", " 8 ": "8"},
+			rejected:  []string{"16 MB"},
+		},
+		{
+			attribute: SpecificationAttribute{Key: "وای فای", Type: SpecificationAttributeBoolean},
+			accepted: map[string]string{
+				"بله": specificationYes, "دارد": specificationYes, "TRUE": specificationYes, "۱": specificationYes,
+				"خیر": specificationNo, "ندارد": specificationNo, "0": specificationNo,
+			},
+			rejected: []string{"شاید"},
+		},
+		{
+			attribute: SpecificationAttribute{Key: "رنگ", Type: SpecificationAttributeSelect, Values: []SpecificationAttributeValue{{Value: "مشکی"}, {Value: "Silver"}}},
+			accepted:  map[string]string{"مشکی": "مشکی", " silver ": "Silver", "مشكي": "مشکی"},
+			rejected:  []string{"قرمز"},
+		},
+		{
+			attribute: SpecificationAttribute{Key: "مدل", Type: SpecificationAttributeText},
+			accepted:  map[string]string{"  Galaxy S24 ": "Galaxy S24"},
+			rejected:  []string{"  "},
+		},
+	}
+
+	for _, a := range attributes {
+		for in, want := range a.accepted {
+			if got, err := a.attribute.parse(in); err != nil || got != want {
+				t.Errorf("%v: parse(%q) = %q, %v, want %q", a.attribute.Key, in, got, err, want)
+			}
+		}
+		for _, in := range a.rejected {
+			if got, err := a.attribute.parse(in); err == nil {
+				t.Errorf("%v: parse(%q) = %q, want an error", a.attribute.Key, in, got)
+			}
+		}
+	}
+}
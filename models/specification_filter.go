@@ -0,0 +1,149 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+)
+
+// SpecificationFilter marks a specification key, such as "RAM", customers
+// can filter products by. Facets are listed in the order of Position.
+type SpecificationFilter struct {
+	ID         uint64     `gorm:"primaryKey"`
+	Key        string     `gorm:"not null;unique"`
+	Position   int        `gorm:"not null;default:0"`
+	ModifiedAt *time.Time `gorm:"type:timestamp with time zone"`
+	CreatedAt  time.Time  `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (SpecificationFilter) TableName() string {
+	return "specification_filter"
+}
+
+// SpecificationFacet is how many of the products found have each value of a
+// filterable specification key.
+type SpecificationFacet struct {
+	Key    string
+	Values []SpecificationFacetValue
+}
+
+type SpecificationFacetValue struct {
+	Value    string
+	Count    int64
+	Selected bool
+}
+
+type SpecificationFilterService struct {
+	repo repository.Repository[SpecificationFilter]
+}
+
+func NewSpecificationFilterService(db *gorm.DB) *SpecificationFilterService {
+	return &SpecificationFilterService{
+		repo: repository.NewGenericRepository[SpecificationFilter](db),
+	}
+}
+
+func (s *SpecificationFilterService) GetAll() (*[]SpecificationFilter, error) {
+	var filters []SpecificationFilter
+	err := s.repo.GetQuery().Order("position, id").Find(&filters).Error
+	return &filters, err
+}
+
+// GetKeys returns every key products have specifications of, to choose the
+// filterable ones from.
+func (s *SpecificationFilterService) GetKeys() ([]string, error) {
+	var keys []string
+	err := s.repo.GetQuery().Model(&Specification{}).Distinct("key").Order("key").Pluck("key", &keys).Error
+	return keys, err
+}
+
+func (s *SpecificationFilterService) GetById(id uint64) (*SpecificationFilter, error) {
+	var filter SpecificationFilter
+	res := s.repo.GetQuery().First(&filter, id)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("فیلتر مورد نظر یافت نشد")
+	}
+	return &filter, res.Error
+}
+
+func (s *SpecificationFilterService) Save(filter *SpecificationFilter) error {
+	var count int64
+	if err := s.repo.GetQuery().Model(&SpecificationFilter{}).Where("key = ? AND id <> ?", filter.Key, filter.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("فیلتر %v قبلا ثبت شده است", filter.Key)
+	}
+	return s.repo.GetQuery().Save(filter).Error
+}
+
+func (s *SpecificationFilterService) Delete(id uint64) error {
+	return s.repo.Delete(id)
+}
+
+// filterableSpecs keeps the keys of specs that are filterable, with their
+// values normalized the way specification values are compared.
+func filterableSpecs(tx *gorm.DB, specs map[string][]string) ([]SpecificationFilter, map[string][]string, error) {
+	var filters []SpecificationFilter
+	if err := tx.Order("position, id").Find(&filters).Error; err != nil {
+		return nil, nil, err
+	}
+
+	filterable := make(map[string][]string)
+	for _, filter := range filters {
+		for _, value := range specs[filter.Key] {
+			filterable[filter.Key] = append(filterable[filter.Key], utils.NormalizePersian(value))
+		}
+	}
+	return filters, filterable, nil
+}
+
+// filterBySpecs keeps the products of query having, for every key of specs,
+// one of its values, leaving out the key except.
+func filterBySpecs(query *gorm.DB, specs map[string][]string, except string) *gorm.DB {
+	for key, values := range specs {
+		if key == except {
+			continue
+		}
+		query = query.Where("product.id IN (SELECT specification.product_id FROM specification WHERE specification.key = ? AND specification.is_active = ? AND "+
+			utils.NormalizePersianSQL("specification.value")+" IN ?)", key, true, values)
+	}
+	return query
+}
+
+// specificationFacets counts the products of base, further narrowed down by
+// specs, having each value of the filterable keys. The values of a key are
+// counted without its own filter, so the other values of a key already
+// filtered by still show how many products they would add.
+func specificationFacets(tx *gorm.DB, base func() *gorm.DB, filters []SpecificationFilter, specs map[string][]string) ([]SpecificationFacet, error) {
+	value := utils.NormalizePersianSQL("specification.value")
+
+	facets := make([]SpecificationFacet, 0, len(filters))
+	for _, filter := range filters {
+		products := filterBySpecs(base(), specs, filter.Key).Select("product.id")
+
+		facet := SpecificationFacet{Key: filter.Key, Values: []SpecificationFacetValue{}}
+		err := tx.Table("specification").
+			Select(value+" AS value, COUNT(DISTINCT specification.product_id) AS count").
+			Where("specification.key = ? AND specification.is_active = ? AND specification.product_id IN (?)", filter.Key, true, products).
+			Group(value).Order("count DESC, value").
+			Scan(&facet.Values).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range facet.Values {
+			for _, selected := range specs[filter.Key] {
+				if facet.Values[i].Value == selected {
+					facet.Values[i].Selected = true
+				}
+			}
+		}
+		facets = append(facets, facet)
+	}
+	return facets, nil
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StockReservationStatus string
+
+const (
+	// stock is held for an order waiting for its payment
+	StockReservationReserved StockReservationStatus = "reserved"
+	// the order was paid and the stock is gone for good
+	StockReservationCommitted StockReservationStatus = "committed"
+	// the payment failed and the stock went back on the shelf
+	StockReservationReleased StockReservationStatus = "released"
+)
+
+type StockReservation struct {
+	ID         uint64                 `gorm:"primaryKey"`
+	OrderID    uint64                 `gorm:"not null;index"`
+	ProductID  uint64                 `gorm:"not null"`
+	VariantID  *uint64                `gorm:"null"`
+	Quantity   int                    `gorm:"not null"`
+	Status     StockReservationStatus `gorm:"not null"`
+	ModifiedAt *time.Time             `gorm:"type:timestamp with time zone"`
+	CreatedAt  time.Time              `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (StockReservation) TableName() string {
+	return "stock_reservation"
+}
+
+// lockProducts loads the given products with SELECT ... FOR UPDATE, so no
+// other checkout can read their stock until tx ends. Rows are locked in id
+// order to keep concurrent checkouts from deadlocking each other.
+func lockProducts(tx *gorm.DB, ids []uint64) (map[uint64]*Product, error) {
+	sorted := append([]uint64(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var products []Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", sorted).Order("id").Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+
+	productsMap := make(map[uint64]*Product)
+	for i := range products {
+		productsMap[products[i].ID] = &products[i]
+	}
+	return productsMap, nil
+}
+
+// reserveStock takes the ordered quantities out of the stock of the
+// products, or of their variants for lines of a variant, and remembers them
+// as reserved for the order. The products and their variants must have been
+// locked by lockProducts and loadVariants in the same transaction.
+func reserveStock(tx *gorm.DB, orderId uint64, products map[uint64]*Product, orderProducts []OrderProduct) error {
+	for _, orderProduct := range orderProducts {
+		product := products[orderProduct.ProductID]
+		if orderProduct.VariantID != nil {
+			variant := product.variant(*orderProduct.VariantID)
+			if variant.Stock < orderProduct.Quantity {
+				return fmt.Errorf("موجودی محصول %v - %v کافی نیست", product.Name, variant.Title)
+			}
+
+			variant.Stock -= orderProduct.Quantity
+			if err := tx.Model(&ProductVariant{}).Where("id = ?", variant.ID).Update("stock", variant.Stock).Error; err != nil {
+				return err
+			}
+		} else {
+			if product.Stock < orderProduct.Quantity {
+				return fmt.Errorf("موجودی محصول %v کافی نیست", product.Name)
+			}
+
+			product.Stock -= orderProduct.Quantity
+			if err := tx.Model(&Product{}).Where("id = ?", product.ID).Update("stock", product.Stock).Error; err != nil {
+				return err
+			}
+		}
+
+		reservation := StockReservation{
+			OrderID:   orderId,
+			ProductID: product.ID,
+			VariantID: orderProduct.VariantID,
+			Quantity:  orderProduct.Quantity,
+			Status:    StockReservationReserved,
+		}
+		if err := tx.Create(&reservation).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// commitStockReservations makes the stock reserved for a paid order permanent.
+func commitStockReservations(tx *gorm.DB, orderId uint64) error {
+	return tx.Model(&StockReservation{}).
+		Where("order_id = ? AND status = ?", orderId, StockReservationReserved).
+		Updates(map[string]any{"status": StockReservationCommitted, "modified_at": time.Now()}).Error
+}
+
+// releaseStockReservations puts the stock reserved for an unpaid order back
+// into the stock of its products and variants.
+func releaseStockReservations(tx *gorm.DB, orderId uint64) error {
+	var reservations []StockReservation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status = ?", orderId, StockReservationReserved).
+		Order("product_id").
+		Find(&reservations).Error
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, reservation := range reservations {
+		if err := restock(tx, reservation.ProductID, reservation.VariantID, reservation.Quantity); err != nil {
+			return err
+		}
+
+		reservation.Status = StockReservationReleased
+		reservation.ModifiedAt = &now
+		if err := tx.Save(&reservation).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restock puts quantity items back into the stock of a product, or of its
+// variant when variantId is set.
+func restock(tx *gorm.DB, productId uint64, variantId *uint64, quantity int) error {
+	if variantId != nil {
+		return tx.Model(&ProductVariant{}).Where("id = ?", *variantId).Update("stock", gorm.Expr("stock + ?", quantity)).Error
+	}
+	return tx.Model(&Product{}).Where("id = ?", productId).Update("stock", gorm.Expr("stock + ?", quantity)).Error
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"gorm.io/gorm"
+)
+
+// TaxClass is a tax rate products are charged by, through the category they
+// belong to. Categories without a class of their own use the class of their
+// closest parent that has one, or the default class. An inclusive class means
+// product prices already contain the tax, otherwise it is added on top.
+type TaxClass struct {
+	ID         uint64     `gorm:"primaryKey"`
+	Name       string     `gorm:"not null"`
+	Rate       float64    `gorm:"not null"`
+	Inclusive  *bool      `gorm:"default:false"`
+	IsDefault  *bool      `gorm:"default:false"`
+	ModifiedAt *time.Time `gorm:"type:timestamp with time zone"`
+	CreatedAt  time.Time  `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (TaxClass) TableName() string {
+	return "tax_class"
+}
+
+// OrderTax is the tax an order was charged for one tax class, over the
+// amount of its lines in that class.
+type OrderTax struct {
+	ID         uint64      `gorm:"primaryKey"`
+	OrderID    uint64      `gorm:"not null;index"`
+	TaxClassID uint64      `gorm:"not null"`
+	Name       string      `gorm:"not null"`
+	Rate       float64     `gorm:"not null"`
+	Inclusive  bool        `gorm:"not null"`
+	Base       utils.Money `gorm:"not null"`
+	Amount     utils.Money `gorm:"not null"`
+	CreatedAt  time.Time   `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (OrderTax) TableName() string {
+	return "order_tax"
+}
+
+type TaxClassService struct {
+	repo repository.Repository[TaxClass]
+}
+
+func NewTaxClassService(db *gorm.DB) *TaxClassService {
+	return &TaxClassService{
+		repo: repository.NewGenericRepository[TaxClass](db),
+	}
+}
+
+func (t *TaxClassService) GetAll() (*[]TaxClass, error) {
+	var taxClasses []TaxClass
+	err := t.repo.GetQuery().Order("id").Find(&taxClasses).Error
+	return &taxClasses, err
+}
+
+func (t *TaxClassService) GetById(id uint64) (*TaxClass, error) {
+	var taxClass TaxClass
+	res := t.repo.GetQuery().First(&taxClass, id)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("کلاس مالیاتی مورد نظر یافت نشد")
+	}
+	return &taxClass, res.Error
+}
+
+// Save creates or updates a tax class. Making a class the default takes it
+// away from the class that was the default before.
+func (t *TaxClassService) Save(taxClass *TaxClass) error {
+	if taxClass.Rate < 0 || taxClass.Rate > 100 {
+		return errors.New("نرخ مالیات باید بین ۰ و ۱۰۰ باشد")
+	}
+
+	return t.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if taxClass.IsDefault != nil && *taxClass.IsDefault {
+			if err := tx.Model(&TaxClass{}).Where("is_default = ? AND id <> ?", true, taxClass.ID).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(taxClass).Error
+	})
+}
+
+func (t *TaxClassService) Delete(id uint64) error {
+	return t.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Category{}).Where("tax_class_id = ?", id).Update("tax_class_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&TaxClass{}, id).Error
+	})
+}
+
+// applyTaxes works out the tax of every line of pricing from the class of its
+// category and adds the tax of exclusive classes to the totals. It runs after
+// every discount, tax is charged on what the customer actually pays.
+// Shipping is not taxed.
+func applyTaxes(tx *gorm.DB, pricing *CartPricing) error {
+	var taxClasses []TaxClass
+	if err := tx.Find(&taxClasses).Error; err != nil {
+		return err
+	}
+	if len(taxClasses) == 0 {
+		return nil
+	}
+
+	var categories []Category
+	if err := tx.Select("id", "parent_id", "tax_class_id").Find(&categories).Error; err != nil {
+		return err
+	}
+
+	taxLines(pricing, taxClasses, categories)
+	return nil
+}
+
+// taxLines is applyTaxes with the tax classes and the categories loaded.
+func taxLines(pricing *CartPricing, taxClasses []TaxClass, categories []Category) {
+	classes := make(map[uint64]*TaxClass, len(taxClasses))
+	var defaultClass *TaxClass
+	for i := range taxClasses {
+		classes[taxClasses[i].ID] = &taxClasses[i]
+		if taxClasses[i].IsDefault != nil && *taxClasses[i].IsDefault {
+			defaultClass = &taxClasses[i]
+		}
+	}
+
+	categoriesMap := make(map[uint64]*Category, len(categories))
+	for i := range categories {
+		categoriesMap[categories[i].ID] = &categories[i]
+	}
+
+	pricing.Tax = 0
+	pricing.Total = 0
+	for i := range pricing.Lines {
+		line := &pricing.Lines[i]
+
+		taxClass := defaultClass
+		category := categoriesMap[line.CategoryID]
+		// the depth guards against a category that is its own ancestor
+		for depth := 0; category != nil && depth <= len(categories); depth++ {
+			if category.TaxClassID != nil && classes[*category.TaxClassID] != nil {
+				taxClass = classes[*category.TaxClassID]
+				break
+			}
+			if category.ParentID == nil {
+				break
+			}
+			category = categoriesMap[*category.ParentID]
+		}
+
+		line.Total = line.Subtotal - line.Discount
+		if taxClass != nil {
+			line.TaxClassID = &taxClass.ID
+			line.TaxRate = taxClass.Rate
+			line.TaxInclusive = taxClass.Inclusive != nil && *taxClass.Inclusive
+			if line.TaxInclusive {
+				line.Tax = line.Total - line.Total.Mul(1/(1+taxClass.Rate/100))
+			} else {
+				line.Tax = line.Total.Percent(taxClass.Rate)
+				line.Total += line.Tax
+			}
+		}
+
+		pricing.Tax += line.Tax
+		pricing.Total += line.Total
+	}
+}
+
+// orderTaxes sums the tax of the lines of an order by tax class.
+func orderTaxes(tx *gorm.DB, orderId uint64, lines []PricedLine) ([]OrderTax, error) {
+	var taxes []OrderTax
+	byClass := make(map[uint64]int)
+	for _, line := range lines {
+		if line.TaxClassID == nil {
+			continue
+		}
+		base := line.Subtotal - line.Discount
+		if line.TaxInclusive {
+			base -= line.Tax
+		}
+
+		i, ok := byClass[*line.TaxClassID]
+		if !ok {
+			var taxClass TaxClass
+			if err := tx.First(&taxClass, *line.TaxClassID).Error; err != nil {
+				return nil, err
+			}
+			i = len(taxes)
+			byClass[*line.TaxClassID] = i
+			taxes = append(taxes, OrderTax{
+				OrderID:    orderId,
+				TaxClassID: taxClass.ID,
+				Name:       taxClass.Name,
+				Rate:       line.TaxRate,
+				Inclusive:  line.TaxInclusive,
+			})
+		}
+		taxes[i].Base += base
+		taxes[i].Amount += line.Tax
+	}
+	return taxes, nil
+}
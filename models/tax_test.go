@@ -0,0 +1,82 @@
+package models
+
+import "testing"
+
+var (
+	taxYes, taxNo = true, false
+
+	testVAT    = TaxClass{ID: 1, Name: "VAT", Rate: 10, Inclusive: &taxYes, IsDefault: &taxYes}
+	testLuxury = TaxClass{ID: 2, Name: "luxury", Rate: 20, Inclusive: &taxNo}
+)
+
+func uint64Ptr(id uint64) *uint64 {
+	return &id
+}
+
+// testCategories is a tree with the luxury class on category 2, a class
+// that doesn't exist on category 4 and a cycle between 5 and 6.
+func testCategories() []Category {
+	return []Category{
+		{ID: 1},
+		{ID: 2, ParentID: uint64Ptr(1), TaxClassID: uint64Ptr(2)},
+		{ID: 3, ParentID: uint64Ptr(2)},
+		{ID: 4, TaxClassID: uint64Ptr(99)},
+		{ID: 5, ParentID: uint64Ptr(6)},
+		{ID: 6, ParentID: uint64Ptr(5)},
+	}
+}
+
+func TestTaxLines(t *testing.T) {
+	pricing := CartPricing{Lines: []PricedLine{
+		{Name: "default inclusive class", CategoryID: 1, Subtotal: 11000},
+		{Name: "own exclusive class", CategoryID: 2, Subtotal: 1005},
+		{Name: "class of the parent after discount", CategoryID: 3, Subtotal: 10000, Discount: 1000},
+		{Name: "unknown class", CategoryID: 4, Subtotal: 5500},
+		{Name: "category cycle", CategoryID: 5, Subtotal: 2200},
+		{Name: "unknown category", CategoryID: 42, Subtotal: 2200},
+		{Name: "fully discounted", CategoryID: 2, Subtotal: 1000, Discount: 1000},
+	}}
+	want := []PricedLine{
+		{TaxClassID: uint64Ptr(1), Tax: 1000, Total: 11000},
+		{TaxClassID: uint64Ptr(2), Tax: 201, Total: 1206},
+		{TaxClassID: uint64Ptr(2), Tax: 1800, Total: 10800},
+		{TaxClassID: uint64Ptr(1), Tax: 500, Total: 5500},
+		{TaxClassID: uint64Ptr(1), Tax: 200, Total: 2200},
+		{TaxClassID: uint64Ptr(1), Tax: 200, Total: 2200},
+		{TaxClassID: uint64Ptr(2), Tax: 0, Total: 0},
+	}
+
+	taxLines(&pricing, []TaxClass{testVAT, testLuxury}, testCategories())
+
+	for i, line := range pricing.Lines {
+		if line.TaxClassID == nil || *line.TaxClassID != *want[i].TaxClassID {
+			t.Errorf("%v: tax class = %v, want %v", line.Name, line.TaxClassID, *want[i].TaxClassID)
+		}
+		if line.Tax != want[i].Tax || line.Total != want[i].Total {
+			t.Errorf("%v: tax %v and total %v, want %v and %v", line.Name, line.Tax, line.Total, want[i].Tax, want[i].Total)
+		}
+	}
+}
+
+func TestTaxLinesWithoutDefaultClass(t *testing.T) {
+	pricing := CartPricing{Lines: []PricedLine{{CategoryID: 1, Subtotal: 2200}}}
+
+	taxLines(&pricing, []TaxClass{testLuxury}, testCategories())
+
+	if line := pricing.Lines[0]; line.TaxClassID != nil || line.Tax != 0 || line.Total != 2200 {
+		t.Errorf("untaxed line has class %v, tax %v and total %v", line.TaxClassID, line.Tax, line.Total)
+	}
+}
+
+func TestTaxLinesTotals(t *testing.T) {
+	pricing := CartPricing{Lines: []PricedLine{
+		{CategoryID: 1, Subtotal: 11000},
+		{CategoryID: 2, Subtotal: 5000, Discount: 500},
+	}}
+
+	taxLines(&pricing, []TaxClass{testVAT, testLuxury}, testCategories())
+
+	if pricing.Tax != 1900 || pricing.Total != 16400 {
+		t.Errorf("pricing tax %v and total %v, want 1900 and 16400", pricing.Tax, pricing.Total)
+	}
+}
@@ -1,7 +1,11 @@
 package models
 
 import (
+	"errors"
+	"time"
+
 	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
 	"gorm.io/gorm"
 )
 
@@ -25,16 +29,38 @@ const (
 	TransactionStatusExpired    TransactionStatus = "expired"
 )
 
+// TransactionStatuses lists every transaction status in the order they are
+// declared in the transaction_status enum.
+var TransactionStatuses = []TransactionStatus{
+	TransactionStatusNew,
+	TransactionStatusSucceed,
+	TransactionStatusFailed,
+	TransactionStatusRollBack,
+	TransactionStatusInProgress,
+	TransactionStatusExpired,
+}
+
 type Transaction struct {
 	ID                       uint64            `gorm:"primaryKey"`
 	CustomerID               uint64            `gorm:"not null"`
 	Device                   string            `gorm:"not null"`
 	Type                     string            `gorm:"not null"`
+	Gateway                  string            `gorm:"not null;default:'zarinpal'"`
+	Authority                *string           `gorm:"null;index"`
 	Status                   TransactionStatus `gorm:"type:transaction_status;not null"`
 	RetrievalReferenceNumber *string           `gorm:"null;unique"`
 	FailureCause             *string           `gorm:"null"`
-	Amount                   float64           `gorm:"not null"`
+	Amount                   utils.Money       `gorm:"not null"`
+	WalletAmount             utils.Money       `gorm:"not null;default:0"`
+	GiftCardAmount           utils.Money       `gorm:"not null;default:0"`
+	CardPan                  *string           `gorm:"null"`
+	CardHash                 *string           `gorm:"null;index"`
+	Fee                      *utils.Money      `gorm:"null"`
+	GatewayResponse          *string           `gorm:"null;type:text"`
 	Description              *string           `gorm:"null;type:text"`
+	ParentID                 *uint64           `gorm:"null;index"`
+	ModifiedAt               *time.Time        `gorm:"type:timestamp with time zone"`
+	CreatedAt                time.Time         `gorm:"type:timestamp with time zone;default:now()"`
 
 	// Relations
 	Order Order `gorm:"foreignKey:TransactionID"`
@@ -61,3 +87,46 @@ func (t *TransactionService) GetById(id uint64) (*Transaction, error) {
 func (t *TransactionService) Update(entity *Transaction) error {
 	return t.repo.Update(entity)
 }
+
+func (t *TransactionService) GetAll(customerId uint64, gateway, authority, refID string, status TransactionStatus, take, skip int) (*[]Transaction, error) {
+	var transactions []Transaction
+	query := t.repo.GetQuery()
+
+	if customerId > 0 {
+		query = query.Where("customer_id = ?", customerId)
+	}
+	if gateway != "" {
+		query = query.Where("gateway = ?", gateway)
+	}
+	if authority != "" {
+		query = query.Where("authority = ?", authority)
+	}
+	if refID != "" {
+		query = query.Where("retrieval_reference_number = ?", refID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	err := query.Order("created_at desc").Offset(skip).Limit(take).Find(&transactions).Error
+	return &transactions, err
+}
+
+// GetPending returns the transactions that were sent to a gateway and are
+// still waiting for the payment to be verified.
+func (t *TransactionService) GetPending() (*[]Transaction, error) {
+	var transactions []Transaction
+	err := t.repo.GetQuery().
+		Where("status IN ? AND authority IS NOT NULL", []TransactionStatus{TransactionStatusNew, TransactionStatusInProgress}).
+		Find(&transactions).Error
+	return &transactions, err
+}
+
+func (t *TransactionService) GetByAuthority(gateway, authority string) (*Transaction, error) {
+	var transaction Transaction
+	res := t.repo.GetQuery().Where("gateway = ? AND authority = ?", gateway, authority).First(&transaction)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("تراکنشی با این شناسه پرداخت یافت نشد")
+	}
+	return &transaction, res.Error
+}
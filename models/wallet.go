@@ -0,0 +1,290 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Hello256World/shop-api/repository"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WalletEntryKind string
+
+const (
+	WalletEntryRefund     WalletEntryKind = "refund"
+	WalletEntryCashback   WalletEntryKind = "cashback"
+	WalletEntryAdjustment WalletEntryKind = "adjustment"
+	// the wallet paid for an order at checkout
+	WalletEntryCheckout WalletEntryKind = "checkout"
+	// an order paid partly with the wallet was never paid, so the wallet got its money back
+	WalletEntryCheckoutReversal WalletEntryKind = "checkout_reversal"
+)
+
+// walletGateway is the gateway of transactions settled entirely with the
+// customer's wallet.
+const walletGateway = "wallet"
+
+// the system accounts the customers' wallets are credited from and debited to
+const (
+	walletAccountRefunds     = "system:refunds"
+	walletAccountCashback    = "system:cashback"
+	walletAccountAdjustments = "system:adjustments"
+	walletAccountSales       = "system:sales"
+)
+
+// WalletAccount is one side of the wallet ledger, either a customer's wallet
+// or a system account. Balance always equals the sum of the account's
+// entries, both are only changed together while the account row is locked.
+type WalletAccount struct {
+	ID         uint64      `gorm:"primaryKey"`
+	Code       string      `gorm:"not null;unique"`
+	CustomerID *uint64     `gorm:"null;unique"`
+	Balance    utils.Money `gorm:"not null;default:0"`
+	ModifiedAt *time.Time  `gorm:"type:timestamp with time zone"`
+	CreatedAt  time.Time   `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (WalletAccount) TableName() string {
+	return "wallet_account"
+}
+
+// WalletEntry is one leg of a posting. Every posting has a debit entry and a
+// credit entry with the same PostingID, and their amounts add up to zero.
+type WalletEntry struct {
+	ID            uint64          `gorm:"primaryKey"`
+	PostingID     string          `gorm:"not null;index"`
+	AccountID     uint64          `gorm:"not null;index"`
+	Kind          WalletEntryKind `gorm:"not null"`
+	Amount        utils.Money     `gorm:"not null"`
+	BalanceAfter  utils.Money     `gorm:"not null"`
+	TransactionID *uint64         `gorm:"null;index"`
+	OrderID       *uint64         `gorm:"null;index"`
+	Description   *string         `gorm:"null;type:text"`
+	CreatedAt     time.Time       `gorm:"type:timestamp with time zone;default:now()"`
+}
+
+func (WalletEntry) TableName() string {
+	return "wallet_entry"
+}
+
+// walletPosting moves Amount from one account to another.
+type walletPosting struct {
+	Kind          WalletEntryKind
+	From          string
+	To            string
+	Amount        utils.Money
+	CustomerID    uint64
+	TransactionID *uint64
+	OrderID       *uint64
+	Description   *string
+}
+
+func customerWalletCode(customerId uint64) string {
+	return fmt.Sprintf("customer:%v", customerId)
+}
+
+type WalletService struct {
+	repo repository.Repository[WalletAccount]
+}
+
+func NewWalletService(db *gorm.DB) *WalletService {
+	return &WalletService{
+		repo: repository.NewGenericRepository[WalletAccount](db),
+	}
+}
+
+// Balance returns what is in the customer's wallet.
+func (w *WalletService) Balance(customerId uint64) (utils.Money, error) {
+	var account WalletAccount
+	res := w.repo.GetQuery().Where("customer_id = ?", customerId).Limit(1).Find(&account)
+	return account.Balance, res.Error
+}
+
+// GetEntries returns the entries of the customer's wallet, newest first.
+func (w *WalletService) GetEntries(customerId uint64, take, skip int) (*[]WalletEntry, error) {
+	var entries []WalletEntry
+	err := w.repo.GetQuery().
+		Joins("JOIN wallet_account ON wallet_account.id = wallet_entry.account_id").
+		Where("wallet_account.customer_id = ?", customerId).
+		Order("wallet_entry.id desc").Offset(skip).Limit(take).
+		Find(&entries).Error
+	return &entries, err
+}
+
+// Adjust credits the customer's wallet with amount, or debits it when amount
+// is negative, on behalf of an admin or for cashback.
+func (w *WalletService) Adjust(customerId uint64, kind WalletEntryKind, amount utils.Money, description string, orderId *uint64) error {
+	if kind != WalletEntryAdjustment && kind != WalletEntryCashback {
+		return errors.New("نوع تراکنش کیف پول نامعتبر است")
+	}
+	if amount == 0 {
+		return errors.New("مبلغ نمی تواند صفر باشد")
+	}
+
+	system := walletAccountAdjustments
+	if kind == WalletEntryCashback {
+		system = walletAccountCashback
+	}
+
+	posting := walletPosting{
+		Kind:        kind,
+		From:        system,
+		To:          customerWalletCode(customerId),
+		Amount:      amount,
+		CustomerID:  customerId,
+		OrderID:     orderId,
+		Description: &description,
+	}
+	if amount < 0 {
+		posting.From, posting.To, posting.Amount = posting.To, posting.From, -amount
+	}
+
+	return w.repo.GetQuery().Transaction(func(tx *gorm.DB) error {
+		return postWallet(tx, posting)
+	})
+}
+
+// postWallet writes a posting inside tx. Both accounts are locked in id
+// order, so concurrent postings can't deadlock or read a stale balance, and a
+// customer's wallet can never go below zero.
+func postWallet(tx *gorm.DB, posting walletPosting) error {
+	if posting.Amount <= 0 {
+		return errors.New("مبلغ تراکنش کیف پول باید بیشتر از صفر باشد")
+	}
+
+	accounts := []WalletAccount{{Code: posting.From}, {Code: posting.To}}
+	for i := range accounts {
+		if accounts[i].Code == customerWalletCode(posting.CustomerID) {
+			accounts[i].CustomerID = &posting.CustomerID
+		}
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&accounts).Error; err != nil {
+		return err
+	}
+
+	var locked []WalletAccount
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code IN ?", []string{posting.From, posting.To}).Order("id").Find(&locked).Error; err != nil {
+		return err
+	}
+
+	var from, to *WalletAccount
+	for i := range locked {
+		switch locked[i].Code {
+		case posting.From:
+			from = &locked[i]
+		case posting.To:
+			to = &locked[i]
+		}
+	}
+	if from == nil || to == nil || from == to {
+		return errors.New("حساب کیف پول نامعتبر است")
+	}
+	if from.CustomerID != nil && from.Balance < posting.Amount {
+		return errors.New("موجودی کیف پول کافی نیست")
+	}
+
+	now := time.Now()
+	postingId := uuid.New().String()
+	from.Balance -= posting.Amount
+	to.Balance += posting.Amount
+
+	for _, leg := range []struct {
+		account *WalletAccount
+		amount  utils.Money
+	}{{from, -posting.Amount}, {to, posting.Amount}} {
+		leg.account.ModifiedAt = &now
+		if err := tx.Save(leg.account).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&WalletEntry{
+			PostingID:     postingId,
+			AccountID:     leg.account.ID,
+			Kind:          posting.Kind,
+			Amount:        leg.amount,
+			BalanceAfter:  leg.account.Balance,
+			TransactionID: posting.TransactionID,
+			OrderID:       posting.OrderID,
+			Description:   posting.Description,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walletBalanceTx returns the customer's balance, locking the wallet until tx
+// ends so it can be debited safely.
+func walletBalanceTx(tx *gorm.DB, customerId uint64) (utils.Money, error) {
+	var account WalletAccount
+	res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("customer_id = ?", customerId).Limit(1).Find(&account)
+	return account.Balance, res.Error
+}
+
+// payWithWallet debits the wallet part of an order's payment.
+func payWithWallet(tx *gorm.DB, order *Order, transaction *Transaction) error {
+	if transaction.WalletAmount <= 0 {
+		return nil
+	}
+	return postWallet(tx, walletPosting{
+		Kind:          WalletEntryCheckout,
+		From:          customerWalletCode(order.CustomerID),
+		To:            walletAccountSales,
+		Amount:        transaction.WalletAmount,
+		CustomerID:    order.CustomerID,
+		TransactionID: &transaction.ID,
+		OrderID:       &order.ID,
+	})
+}
+
+// releaseWalletPayment gives the wallet part of an unpaid order back. It is
+// safe to call more than once.
+func releaseWalletPayment(tx *gorm.DB, order *Order) error {
+	var entries []WalletEntry
+	if err := tx.Joins("JOIN wallet_account ON wallet_account.id = wallet_entry.account_id").
+		Where("wallet_entry.order_id = ? AND wallet_account.customer_id IS NOT NULL AND wallet_entry.kind IN ?", order.ID, []WalletEntryKind{WalletEntryCheckout, WalletEntryCheckoutReversal}).
+		Find(&entries).Error; err != nil {
+		return err
+	}
+
+	var paid utils.Money
+	var transactionId *uint64
+	for _, entry := range entries {
+		paid -= entry.Amount
+		transactionId = entry.TransactionID
+	}
+	if paid <= 0 {
+		return nil
+	}
+
+	return postWallet(tx, walletPosting{
+		Kind:          WalletEntryCheckoutReversal,
+		From:          walletAccountSales,
+		To:            customerWalletCode(order.CustomerID),
+		Amount:        paid,
+		CustomerID:    order.CustomerID,
+		TransactionID: transactionId,
+		OrderID:       &order.ID,
+	})
+}
+
+// refundToWallet credits the wallet part of a refund.
+func refundToWallet(tx *gorm.DB, order *Order, refund *Transaction) error {
+	if refund.WalletAmount <= 0 {
+		return nil
+	}
+	return postWallet(tx, walletPosting{
+		Kind:          WalletEntryRefund,
+		From:          walletAccountRefunds,
+		To:            customerWalletCode(order.CustomerID),
+		Amount:        refund.WalletAmount,
+		CustomerID:    order.CustomerID,
+		TransactionID: &refund.ID,
+		OrderID:       &order.ID,
+		Description:   refund.Description,
+	})
+}
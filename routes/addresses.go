@@ -35,6 +35,7 @@ func (a *AddressHandler) getAllActive(c *gin.Context) {
 func (a *AddressHandler) create(c *gin.Context) {
 	var inputAddress struct {
 		ReceiverName string `form:"receiver_name" binding:"required"`
+		Province     string `form:"province" binding:"required"`
 		Address      string `form:"address" binding:"required"`
 		Phone        string `form:"phone" binding:"required,phone"`
 		NO           string `form:"no" binding:"required"`
@@ -42,14 +43,20 @@ func (a *AddressHandler) create(c *gin.Context) {
 	}
 
 	if err := c.ShouldBind(&inputAddress); err != nil {
-		getErrors := utils.FormValidation(err.Error(), map[string]string{"ReceiverName": "نام دریافت کننده", "Address": "آدرس", "Phone": "تلفن همراه", "NO": "پلاک", "Unit": "واحد"})
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"ReceiverName": "نام دریافت کننده", "Province": "استان", "Address": "آدرس", "Phone": "تلفن همراه", "NO": "پلاک", "Unit": "واحد"})
 		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors, "error": err.Error()})
 		return
 	}
 
+	if _, ok := models.ShippingZones[inputAddress.Province]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "استان وارد شده معتبر نیست"})
+		return
+	}
+
 	address := models.Address{
 		ReceiverName: inputAddress.ReceiverName,
 		CustomerID:   c.GetUint64("customerId"),
+		Province:     inputAddress.Province,
 		Address:      inputAddress.Address,
 		Phone:        inputAddress.Phone,
 		NO:           inputAddress.NO,
@@ -82,6 +89,7 @@ func (a *AddressHandler) update(c *gin.Context) {
 
 	var inputAddress struct {
 		ReceiverName string `form:"receiver_name" binding:"required"`
+		Province     string `form:"province" binding:"required"`
 		Address      string `form:"address" binding:"required"`
 		Phone        string `form:"phone" binding:"required,phone"`
 		NO           string `form:"no" binding:"required"`
@@ -90,12 +98,18 @@ func (a *AddressHandler) update(c *gin.Context) {
 	}
 
 	if err := c.ShouldBind(&inputAddress); err != nil {
-		getErrors := utils.FormValidation(err.Error(), map[string]string{"ReceiverName": "نام دریافت کننده", "Address": "آدرس", "Phone": "تلفن همراه", "NO": "پلاک", "Unit": "واحد", "IsDelete": "غیرفعال"})
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"ReceiverName": "نام دریافت کننده", "Province": "استان", "Address": "آدرس", "Phone": "تلفن همراه", "NO": "پلاک", "Unit": "واحد", "IsDelete": "غیرفعال"})
 		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors, "error": err.Error()})
 		return
 	}
 
+	if _, ok := models.ShippingZones[inputAddress.Province]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "استان وارد شده معتبر نیست"})
+		return
+	}
+
 	now := time.Now()
+	address.Province = inputAddress.Province
 	address.Address = inputAddress.Address
 	address.ReceiverName = inputAddress.ReceiverName
 	address.Phone = inputAddress.Phone
@@ -3,6 +3,7 @@ package routes
 import (
 	"context"
 	"fmt"
+	"log"
 	"math/rand"
 	"net/http"
 	"time"
@@ -17,12 +18,14 @@ import (
 type AuthHandler struct {
 	customerService *models.CustomerService
 	cartService     *models.CartService
+	loyaltyService  *models.LoyaltyService
 }
 
 func NewAuthHandler(db *gorm.DB) *AuthHandler {
 	return &AuthHandler{
 		customerService: models.NewCustomerService(db),
 		cartService:     models.NewCartService(db),
+		loyaltyService:  models.NewLoyaltyService(db),
 	}
 }
 
@@ -62,6 +65,11 @@ func (a *AuthHandler) signup(c *gin.Context) {
 		return
 	}
 
+	// the customer is signed up already, a missing bonus can be granted later
+	if err := a.loyaltyService.GrantSignupBonus(customer.ID); err != nil {
+		log.Printf("signup bonus of customer %v: %v", customer.ID, err)
+	}
+
 	c.JSON(http.StatusCreated, gin.H{"message": "شما با موفقیت ثبت نام کردید"})
 }
 
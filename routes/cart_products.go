@@ -15,6 +15,7 @@ type CartProductHandler struct {
 	cartProductService *models.CartProductService
 	cartService        *models.CartService
 	productService     *models.ProductService
+	variantService     *models.ProductVariantService
 }
 
 func NewCartProductHandler(db *gorm.DB) *CartProductHandler {
@@ -22,6 +23,7 @@ func NewCartProductHandler(db *gorm.DB) *CartProductHandler {
 		models.NewCartProductService(db),
 		models.NewCartService(db),
 		models.NewProductService(db),
+		models.NewProductVariantService(db),
 	}
 }
 
@@ -76,8 +78,9 @@ func (ch *CartProductHandler) deleteAll(c *gin.Context) {
 
 func (ch *CartProductHandler) create(c *gin.Context) {
 	var inputCartProduct struct {
-		ProductID uint64 `form:"product_id" binding:"required"`
-		Quantity  *int   `form:"quantity" binding:"required,gt=0"`
+		ProductID uint64  `form:"product_id" binding:"required"`
+		VariantID *uint64 `form:"variant_id"`
+		Quantity  *int    `form:"quantity" binding:"required,gt=0"`
 	}
 
 	if err := c.ShouldBind(&inputCartProduct); err != nil {
@@ -98,7 +101,32 @@ func (ch *CartProductHandler) create(c *gin.Context) {
 		return
 	}
 
-	if product.Stock < *inputCartProduct.Quantity {
+	variants, err := ch.variantService.GetAll(product.ID)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت تنوع های محصول", "error": err.Error()})
+		return
+	}
+
+	stock := product.Stock
+	if inputCartProduct.VariantID != nil {
+		var variant *models.ProductVariant
+		for i := range *variants {
+			if (*variants)[i].ID == *inputCartProduct.VariantID {
+				variant = &(*variants)[i]
+			}
+		}
+		if variant == nil || !variant.Available() {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "تنوع محصول نامعتبر است"})
+			return
+		}
+		stock = variant.Stock
+	} else if len(*variants) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "لطفا یکی از تنوع های محصول را انتخاب کنید"})
+		return
+	}
+
+	if stock < *inputCartProduct.Quantity {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "درخواست تعداد محصول بیشتر از موجودی می باشد"})
 		return
 	}
@@ -111,7 +139,7 @@ func (ch *CartProductHandler) create(c *gin.Context) {
 	}
 
 	for _, value := range cart.CartProducts {
-		if value.ProductID == inputCartProduct.ProductID {
+		if value.ProductID == inputCartProduct.ProductID && models.SameVariant(value.VariantID, inputCartProduct.VariantID) {
 			now := time.Now()
 			value.Quantity += *inputCartProduct.Quantity
 			value.ModifiedAt = &now
@@ -126,6 +154,7 @@ func (ch *CartProductHandler) create(c *gin.Context) {
 
 	cartProduct := models.CartProduct{
 		ProductID: inputCartProduct.ProductID,
+		VariantID: inputCartProduct.VariantID,
 		Quantity:  *inputCartProduct.Quantity,
 		CartID:    cart.ID,
 	}
@@ -4,17 +4,20 @@ import (
 	"net/http"
 
 	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type CartHandler struct {
-	cartService *models.CartService
+	cartService   *models.CartService
+	couponService *models.CouponService
 }
 
 func NewCartHandler(db *gorm.DB) *CartHandler {
 	return &CartHandler{
-		cartService: models.NewCartService(db),
+		cartService:   models.NewCartService(db),
+		couponService: models.NewCouponService(db),
 	}
 }
 
@@ -27,5 +30,47 @@ func (ch *CartHandler) getAll(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"cart": cart})
+	if len(cart.CartProducts) == 0 {
+		c.JSON(http.StatusOK, gin.H{"cart": cart})
+		return
+	}
+
+	// the cart is shown even when it can't be priced, so its invalid
+	// products can be removed; only checkout refuses it
+	pricing, lineErrors, err := ch.cartService.Price(customerId)
+
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"cart": cart, "pricing": nil, "message": "خطا در محاسبه مبلغ سبد خرید", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cart": cart, "pricing": pricing, "line_errors": lineErrors})
+}
+
+func (ch *CartHandler) applyCoupon(c *gin.Context) {
+	var inputCoupon struct {
+		Code string `form:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBind(&inputCoupon); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Code": "کد تخفیف"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	if _, err := ch.couponService.Apply(c.GetUint64("customerId"), inputCoupon.Code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "کد تخفیف با موفقیت اعمال شد"})
+}
+
+func (ch *CartHandler) removeCoupon(c *gin.Context) {
+	if err := ch.couponService.Remove(c.GetUint64("customerId")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در حذف کد تخفیف", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "کد تخفیف با موفقیت حذف شد"})
 }
\ No newline at end of file
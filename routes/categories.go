@@ -16,11 +16,13 @@ import (
 
 type CategoryHandler struct {
 	categoryService *models.CategoryService
+	taxClassService *models.TaxClassService
 }
 
 func NewCategoryHandler(db *gorm.DB) *CategoryHandler {
 	return &CategoryHandler{
 		categoryService: models.NewCategoryService(db),
+		taxClassService: models.NewTaxClassService(db),
 	}
 }
 
@@ -90,9 +92,10 @@ func (ch *CategoryHandler) getAllActive(c *gin.Context) {
 
 func (ch *CategoryHandler) create(c *gin.Context) {
 	var inputCategory struct {
-		Name     string                `form:"name" binding:"required"`
-		ParentID *uint64               `form:"parentId"`
-		File     *multipart.FileHeader `form:"file" binding:"required"`
+		Name       string                `form:"name" binding:"required"`
+		ParentID   *uint64               `form:"parentId"`
+		File       *multipart.FileHeader `form:"file" binding:"required"`
+		TaxClassID *uint64               `form:"taxClassId"`
 	}
 
 	if err := c.ShouldBind(&inputCategory); err != nil {
@@ -100,6 +103,13 @@ func (ch *CategoryHandler) create(c *gin.Context) {
 		return
 	}
 
+	if inputCategory.TaxClassID != nil {
+		if _, err := ch.taxClassService.GetById(*inputCategory.TaxClassID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+	}
+
 	imageLocation, err := utils.AddImageToServer(c, "category", "thumbnail", inputCategory.File)
 
 	if err != nil {
@@ -108,9 +118,10 @@ func (ch *CategoryHandler) create(c *gin.Context) {
 	}
 
 	category := models.Category{
-		Name:     inputCategory.Name,
-		Image:    fmt.Sprintf("https://%v", *imageLocation),
-		ParentID: inputCategory.ParentID,
+		Name:       inputCategory.Name,
+		Image:      fmt.Sprintf("https://%v", *imageLocation),
+		ParentID:   inputCategory.ParentID,
+		TaxClassID: inputCategory.TaxClassID,
 	}
 
 	isOk := ch.categoryService.Create(category)
@@ -157,10 +168,11 @@ func (ch *CategoryHandler) update(c *gin.Context) {
 	}
 
 	var inputCategory struct {
-		Name     string                `form:"name" binding:"required"`
-		File     *multipart.FileHeader `form:"file"`
-		ParentID *uint64               `form:"parent_Id"`
-		IsActive *bool                 `form:"is_active"`
+		Name       string                `form:"name" binding:"required"`
+		File       *multipart.FileHeader `form:"file"`
+		ParentID   *uint64               `form:"parent_Id"`
+		IsActive   *bool                 `form:"is_active"`
+		TaxClassID *uint64               `form:"tax_class_id"`
 	}
 
 	if err := c.ShouldBind(&inputCategory); err != nil {
@@ -168,6 +180,13 @@ func (ch *CategoryHandler) update(c *gin.Context) {
 		return
 	}
 
+	if inputCategory.TaxClassID != nil {
+		if _, err := ch.taxClassService.GetById(*inputCategory.TaxClassID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+	}
+
 	if inputCategory.File != nil {
 		imageLocation, err := utils.AddImageToServer(c, "category", "thumbnail", inputCategory.File)
 
@@ -191,6 +210,7 @@ func (ch *CategoryHandler) update(c *gin.Context) {
 	category.ModifiedAt = &now
 	category.Name = inputCategory.Name
 	category.ParentID = inputCategory.ParentID
+	category.TaxClassID = inputCategory.TaxClassID
 	if inputCategory.IsActive != nil {
 		category.IsActive = inputCategory.IsActive
 	}
@@ -89,7 +89,7 @@ func (cp *CompareProductHandler) create(c *gin.Context) {
 	var inputCompareProduct struct {
 		Name  string                `form:"name" binding:"required"`
 		Link  string                `form:"link" binding:"required"`
-		Price float64               `form:"price" binding:"required"`
+		Price utils.Money           `form:"price" binding:"required"`
 		Image *multipart.FileHeader `form:"file" binding:"required"`
 	}
 
@@ -151,7 +151,7 @@ func (cp *CompareProductHandler) update(c *gin.Context) {
 	var inputCompareProduct struct {
 		Name     string                `form:"name" binding:"required"`
 		Link     string                `form:"link" binding:"required"`
-		Price    float64               `form:"price" binding:"required"`
+		Price    utils.Money           `form:"price" binding:"required"`
 		IsActive *bool                 `form:"is_active" binding:"required"`
 		IsDelete *bool                 `form:"is_delete" binding:"required"`
 		Image    *multipart.FileHeader `form:"file"`
@@ -0,0 +1,142 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CouponHandler struct {
+	couponService *models.CouponService
+}
+
+func NewCouponHandler(db *gorm.DB) *CouponHandler {
+	return &CouponHandler{
+		couponService: models.NewCouponService(db),
+	}
+}
+
+type couponInput struct {
+	Code             string            `form:"code" binding:"required"`
+	Type             models.CouponType `form:"type" binding:"required"`
+	Percentage       float64           `form:"percentage" binding:"gte=0,lte=100"`
+	Amount           utils.Money       `form:"amount" binding:"gte=0"`
+	MaxDiscount      *utils.Money      `form:"max_discount" binding:"omitempty,gt=0"`
+	MinBasket        *utils.Money      `form:"min_basket" binding:"omitempty,gte=0"`
+	UsageLimit       *int              `form:"usage_limit" binding:"omitempty,gt=0"`
+	PerCustomerLimit *int              `form:"per_customer_limit" binding:"omitempty,gt=0"`
+	StartsAt         *time.Time        `form:"starts_at" time_format:"2006-01-02T15:04:05Z07:00"`
+	ExpiresAt        *time.Time        `form:"expires_at" time_format:"2006-01-02T15:04:05Z07:00"`
+	IsActive         *bool             `form:"is_active"`
+	CategoryIDs      []uint64          `form:"category_id"`
+	ProductIDs       []uint64          `form:"product_id"`
+}
+
+var couponInputFields = map[string]string{"Code": "کد", "Type": "نوع", "Percentage": "درصد تخفیف", "Amount": "مبلغ تخفیف", "MaxDiscount": "حداکثر تخفیف", "MinBasket": "حداقل مبلغ خرید", "UsageLimit": "تعداد استفاده", "PerCustomerLimit": "تعداد استفاده هر مشتری", "StartsAt": "زمان شروع", "ExpiresAt": "زمان پایان", "IsActive": "فعال", "CategoryIDs": "دسته بندی ها", "ProductIDs": "محصولات"}
+
+func (input *couponInput) fill(coupon *models.Coupon) {
+	coupon.Code = input.Code
+	coupon.Type = input.Type
+	coupon.Percentage = input.Percentage
+	coupon.Amount = input.Amount
+	coupon.MaxDiscount = input.MaxDiscount
+	coupon.MinBasket = input.MinBasket
+	coupon.UsageLimit = input.UsageLimit
+	coupon.PerCustomerLimit = input.PerCustomerLimit
+	coupon.StartsAt = input.StartsAt
+	coupon.ExpiresAt = input.ExpiresAt
+	if input.IsActive != nil {
+		coupon.IsActive = input.IsActive
+	}
+
+	coupon.Categories = nil
+	for _, id := range input.CategoryIDs {
+		coupon.Categories = append(coupon.Categories, models.Category{ID: id})
+	}
+	coupon.Products = nil
+	for _, id := range input.ProductIDs {
+		coupon.Products = append(coupon.Products, models.Product{ID: id})
+	}
+}
+
+func (co *CouponHandler) getAll(c *gin.Context) {
+	take := c.Query("take")
+	skip := c.Query("skip")
+	takeInt, err := strconv.Atoi(take)
+	if err != nil {
+		takeInt = 10
+	}
+	skipInt, err := strconv.Atoi(skip)
+	if err != nil {
+		skipInt = 0
+	}
+
+	coupons, err := co.couponService.GetAll(c.Query("code"), takeInt, skipInt)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت کدهای تخفیف", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"coupons": coupons})
+}
+
+func (co *CouponHandler) create(c *gin.Context) {
+	var inputCoupon couponInput
+
+	if err := c.ShouldBind(&inputCoupon); err != nil {
+		getErrors := utils.FormValidation(err.Error(), couponInputFields)
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	var coupon models.Coupon
+	inputCoupon.fill(&coupon)
+
+	if err := co.couponService.Save(&coupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ذخیره کد تخفیف", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "کد تخفیف با موفقیت ذخیره شد", "coupon": coupon})
+}
+
+func (co *CouponHandler) update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه کد تخفیف"})
+		return
+	}
+
+	coupon, err := co.couponService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputCoupon couponInput
+
+	if err := c.ShouldBind(&inputCoupon); err != nil {
+		getErrors := utils.FormValidation(err.Error(), couponInputFields)
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	now := time.Now()
+	inputCoupon.fill(coupon)
+	coupon.ModifiedAt = &now
+
+	if err := co.couponService.Save(coupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی کد تخفیف", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "کد تخفیف با موفقیت بروزرسانی شد"})
+}
@@ -0,0 +1,145 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type GiftCardHandler struct {
+	giftCardService *models.GiftCardService
+}
+
+func NewGiftCardHandler(db *gorm.DB) *GiftCardHandler {
+	return &GiftCardHandler{
+		giftCardService: models.NewGiftCardService(db),
+	}
+}
+
+func (g *GiftCardHandler) getAll(c *gin.Context) {
+	take := c.Query("take")
+	skip := c.Query("skip")
+	takeInt, err := strconv.Atoi(take)
+	if err != nil {
+		takeInt = 10
+	}
+	skipInt, err := strconv.Atoi(skip)
+	if err != nil {
+		skipInt = 0
+	}
+
+	giftCards, err := g.giftCardService.GetAll(c.Query("code"), 0, takeInt, skipInt)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت کارت های هدیه", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"gift_cards": giftCards})
+}
+
+// getPurchased lists the gift cards the customer bought.
+func (g *GiftCardHandler) getPurchased(c *gin.Context) {
+	take := c.Query("take")
+	skip := c.Query("skip")
+	takeInt, err := strconv.Atoi(take)
+	if err != nil {
+		takeInt = 10
+	}
+	skipInt, err := strconv.Atoi(skip)
+	if err != nil {
+		skipInt = 0
+	}
+
+	giftCards, err := g.giftCardService.GetAll("", c.GetUint64("customerId"), takeInt, skipInt)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت کارت های هدیه", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"gift_cards": giftCards})
+}
+
+// check shows the balance of a gift card to whoever holds its code.
+func (g *GiftCardHandler) check(c *gin.Context) {
+	giftCard, err := g.giftCardService.GetByCode(c.Param("code"))
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	usable := (giftCard.IsActive == nil || *giftCard.IsActive) && (giftCard.ExpiresAt == nil || time.Now().Before(*giftCard.ExpiresAt))
+	c.JSON(http.StatusOK, gin.H{"balance": giftCard.Balance, "expiresAt": giftCard.ExpiresAt, "usable": usable && giftCard.Balance > 0})
+}
+
+func (g *GiftCardHandler) create(c *gin.Context) {
+	var inputGiftCard struct {
+		Balance   *utils.Money `form:"balance" binding:"required,gt=0"`
+		ExpiresAt *time.Time   `form:"expires_at" time_format:"2006-01-02T15:04:05Z07:00"`
+	}
+
+	if err := c.ShouldBind(&inputGiftCard); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Balance": "مبلغ", "ExpiresAt": "تاریخ انقضا"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	giftCard := models.GiftCard{
+		InitialBalance: *inputGiftCard.Balance,
+		ExpiresAt:      inputGiftCard.ExpiresAt,
+		IssuedBy:       models.OrderChangedBy(c.GetString("role"), c.GetUint64("adminId")),
+	}
+
+	if err := g.giftCardService.Issue(&giftCard); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در صدور کارت هدیه", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "کارت هدیه با موفقیت صادر شد", "gift_card": giftCard})
+}
+
+func (g *GiftCardHandler) update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه کارت هدیه"})
+		return
+	}
+
+	giftCard, err := g.giftCardService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputGiftCard struct {
+		ExpiresAt *time.Time `form:"expires_at" time_format:"2006-01-02T15:04:05Z07:00"`
+		IsActive  *bool      `form:"is_active" binding:"required"`
+	}
+
+	if err := c.ShouldBind(&inputGiftCard); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"ExpiresAt": "تاریخ انقضا", "IsActive": "فعال"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	now := time.Now()
+	giftCard.ExpiresAt = inputGiftCard.ExpiresAt
+	giftCard.IsActive = inputGiftCard.IsActive
+	giftCard.ModifiedAt = &now
+
+	if err := g.giftCardService.Update(giftCard); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی کارت هدیه", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "کارت هدیه با موفقیت بروزرسانی شد"})
+}
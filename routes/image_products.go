@@ -16,12 +16,14 @@ import (
 type ImageProductHandler struct {
 	imageProductService *models.ImageProductService
 	productService      *models.ProductService
+	variantService      *models.ProductVariantService
 }
 
 func NewImageProductHandler(db *gorm.DB) *ImageProductHandler {
 	return &ImageProductHandler{
 		imageProductService: models.NewImageProductService(db),
 		productService:      models.NewProductService(db),
+		variantService:      models.NewProductVariantService(db),
 	}
 }
 
@@ -57,8 +59,9 @@ func (i *ImageProductHandler) create(c *gin.Context) {
 	}
 
 	var inputImageProducts struct {
-		Image    *multipart.FileHeader `form:"file" binding:"required"`
-		Priority *int                  `form:"priority" binding:"required,gte=0"`
+		Image     *multipart.FileHeader `form:"file" binding:"required"`
+		Priority  *int                  `form:"priority" binding:"required,gte=0"`
+		VariantID *uint64               `form:"variant_id"`
 	}
 
 	if err := c.ShouldBind(&inputImageProducts); err != nil {
@@ -67,6 +70,14 @@ func (i *ImageProductHandler) create(c *gin.Context) {
 		return
 	}
 
+	if inputImageProducts.VariantID != nil {
+		variant, err := i.variantService.GetById(*inputImageProducts.VariantID)
+		if err != nil || variant.ProductID != id {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "تنوع محصول نامعتبر است"})
+			return
+		}
+	}
+
 	imageLocation, err := utils.AddImageToServer(c, "productsimage", "images", inputImageProducts.Image)
 
 	if err != nil {
@@ -78,6 +89,7 @@ func (i *ImageProductHandler) create(c *gin.Context) {
 		Image:     *imageLocation,
 		Priority:  *inputImageProducts.Priority,
 		ProductID: id,
+		VariantID: inputImageProducts.VariantID,
 	}
 
 	if err := i.imageProductService.Create(imageProduct); err != nil {
@@ -0,0 +1,156 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LoyaltyHandler struct {
+	loyaltyService *models.LoyaltyService
+}
+
+func NewLoyaltyHandler(db *gorm.DB) *LoyaltyHandler {
+	return &LoyaltyHandler{
+		loyaltyService: models.NewLoyaltyService(db),
+	}
+}
+
+func (l *LoyaltyHandler) respondPoints(c *gin.Context, customerId uint64) {
+	take := c.Query("take")
+	skip := c.Query("skip")
+	takeInt, err := strconv.Atoi(take)
+	if err != nil {
+		takeInt = 10
+	}
+	skipInt, err := strconv.Atoi(skip)
+	if err != nil {
+		skipInt = 0
+	}
+
+	program, err := l.loyaltyService.GetProgram()
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت باشگاه مشتریان", "error": err.Error()})
+		return
+	}
+
+	balance, err := l.loyaltyService.Balance(customerId)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت امتیاز باشگاه مشتریان", "error": err.Error()})
+		return
+	}
+
+	entries, err := l.loyaltyService.GetEntries(customerId, takeInt, skipInt)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت تاریخچه امتیازها", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"points": balance, "pointValue": program.PointValue, "entries": entries})
+}
+
+func (l *LoyaltyHandler) getByCustomer(c *gin.Context) {
+	l.respondPoints(c, c.GetUint64("customerId"))
+}
+
+func (l *LoyaltyHandler) get(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه مشتری"})
+		return
+	}
+
+	l.respondPoints(c, id)
+}
+
+func (l *LoyaltyHandler) adjust(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه مشتری"})
+		return
+	}
+
+	var inputAdjustment struct {
+		Points      *int   `form:"points" binding:"required,ne=0"`
+		Description string `form:"description" binding:"required"`
+	}
+
+	if err := c.ShouldBind(&inputAdjustment); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Points": "امتیاز", "Description": "توضیحات"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	description := models.OrderChangedBy(c.GetString("role"), c.GetUint64("adminId")) + ": " + inputAdjustment.Description
+	if err := l.loyaltyService.Adjust(id, *inputAdjustment.Points, description); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ثبت امتیاز", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "امتیاز مشتری با موفقیت بروزرسانی شد"})
+}
+
+func (l *LoyaltyHandler) getProgram(c *gin.Context) {
+	program, err := l.loyaltyService.GetProgram()
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت باشگاه مشتریان", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"program": program})
+}
+
+func (l *LoyaltyHandler) updateProgram(c *gin.Context) {
+	var inputProgram struct {
+		EarnRate         float64     `json:"earn_rate" binding:"gte=0"`
+		PointValue       utils.Money `json:"point_value" binding:"gte=0"`
+		MaxRedeemPercent float64     `json:"max_redeem_percent" binding:"gte=0,lte=100"`
+		SignupBonus      int         `json:"signup_bonus" binding:"gte=0"`
+		BirthdayBonus    int         `json:"birthday_bonus" binding:"gte=0"`
+		ExpiryDays       int         `json:"expiry_days" binding:"gte=0"`
+		IsActive         *bool       `json:"is_active" binding:"required"`
+		Multipliers      []struct {
+			CategoryID uint64  `json:"category_id" binding:"required"`
+			Multiplier float64 `json:"multiplier" binding:"gte=0"`
+		} `json:"multipliers" binding:"dive"`
+	}
+
+	if err := c.ShouldBindJSON(&inputProgram); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"EarnRate": "امتیاز هر تومان", "PointValue": "ارزش هر امتیاز", "MaxRedeemPercent": "حداکثر درصد پرداخت با امتیاز", "SignupBonus": "امتیاز ثبت نام", "BirthdayBonus": "امتیاز تولد", "ExpiryDays": "مدت اعتبار امتیاز", "IsActive": "فعال", "Multipliers": "ضرایب دسته بندی", "CategoryID": "دسته بندی", "Multiplier": "ضریب"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	program := models.LoyaltyProgram{
+		EarnRate:         inputProgram.EarnRate,
+		PointValue:       inputProgram.PointValue,
+		MaxRedeemPercent: inputProgram.MaxRedeemPercent,
+		SignupBonus:      inputProgram.SignupBonus,
+		BirthdayBonus:    inputProgram.BirthdayBonus,
+		ExpiryDays:       inputProgram.ExpiryDays,
+		IsActive:         inputProgram.IsActive,
+	}
+	for _, multiplier := range inputProgram.Multipliers {
+		program.Multipliers = append(program.Multipliers, models.LoyaltyMultiplier{
+			CategoryID: multiplier.CategoryID,
+			Multiplier: multiplier.Multiplier,
+		})
+	}
+
+	if err := l.loyaltyService.SaveProgram(&program); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ذخیره باشگاه مشتریان", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "باشگاه مشتریان با موفقیت بروزرسانی شد", "program": program})
+}
@@ -1,8 +1,10 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -15,24 +17,22 @@ import (
 )
 
 type OrderHandler struct {
-	orderService        *models.OrderService
-	addressService      *models.AddressService
-	cartService         *models.CartService
-	productService      *models.ProductService
-	orderProductService *models.OrderProductService
-	cartProductService  *models.CartProductService
-	transactionService  *models.TransactionService
+	orderService       *models.OrderService
+	addressService     *models.AddressService
+	transactionService *models.TransactionService
+	paymentService     *models.PaymentService
+	checkoutService    *models.CheckoutService
+	refundService      *models.RefundService
 }
 
 func NewOrderHandler(db *gorm.DB) *OrderHandler {
 	return &OrderHandler{
-		orderService:        models.NewOrderService(db),
-		addressService:      models.NewAddressService(db),
-		cartService:         models.NewCartService(db),
-		productService:      models.NewProductService(db),
-		orderProductService: models.NewOrderProductService(db),
-		cartProductService:  models.NewCartProductService(db),
-		transactionService:  models.NewTransactionService(db),
+		orderService:       models.NewOrderService(db),
+		addressService:     models.NewAddressService(db),
+		transactionService: models.NewTransactionService(db),
+		paymentService:     models.NewPaymentService(db),
+		checkoutService:    models.NewCheckoutService(db),
+		refundService:      models.NewRefundService(db),
 	}
 }
 
@@ -96,28 +96,39 @@ func (o *OrderHandler) update(c *gin.Context) {
 		Weight          *float64           `form:"weight" binding:"required,gt=0"`
 		DeliverMethod   string             `form:"deliver_method" binding:"required"`
 		RejectionReason *string            `form:"rejection_reason"`
-		TotalAmount     *float64           `form:"total_amount" binding:"required,gt=0"`
 		DeliveryAddress string             `form:"delivery_address" binding:"required"`
 		Status          models.OrderStatus `form:"status" binding:"required"`
+		Reason          *string            `form:"reason"`
 	}
 
 	if err := c.ShouldBind(&inputOrder); err != nil {
-		getErrors := utils.FormValidation(err.Error(), map[string]string{"CustomerName": "نام مشتری", "Phone": "تلفن همراه", "Description": "توضیحات", "Weight": "وزن", "DeliverMethod": "روش ارسال", "RejectionReason": "دلیل رد شدن", "TotalAmount": "کل حساب", "DeliveryAddress": "آدرس ارسال", "Status": "وضعیت"})
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"CustomerName": "نام مشتری", "Phone": "تلفن همراه", "Description": "توضیحات", "Weight": "وزن", "DeliverMethod": "روش ارسال", "RejectionReason": "دلیل رد شدن", "DeliveryAddress": "آدرس ارسال", "Status": "وضعیت", "Reason": "دلیل تغییر وضعیت"})
 		c.JSON(http.StatusNotAcceptable, gin.H{"message": getErrors})
 		return
 	}
 
+	// cancelling and refunding pay the customer back, which only the refund flow does
+	if inputOrder.Status != order.Status && (inputOrder.Status == models.OrderStatusCancelled || inputOrder.Status == models.OrderStatusRefunded) {
+		c.JSON(http.StatusNotAcceptable, gin.H{"message": "برای لغو یا بازپرداخت سفارش از بخش بازپرداخت سفارش استفاده کنید"})
+		return
+	}
+
+	if inputOrder.Status != order.Status && !order.Status.CanTransitionTo(inputOrder.Status) {
+		c.JSON(http.StatusNotAcceptable, gin.H{"message": fmt.Sprintf("تغییر وضعیت سفارش از %v به %v مجاز نیست", order.Status, inputOrder.Status)})
+		return
+	}
+
+	reason := inputOrder.Reason
 	if inputOrder.Status == models.OrderStatusRejected {
-		if order.Status == models.OrderStatusConfirmed {
-			c.JSON(http.StatusNotAcceptable, gin.H{"message": "سفارش تایید شده است و نمی توان آن را رد کرد"})
-			return
-		} else if inputOrder.RejectionReason == nil {
+		if inputOrder.RejectionReason == nil {
 			c.JSON(http.StatusNotAcceptable, gin.H{"message": "دلیل رد شدن سفارش را وارد کنید"})
 			return
 		}
+		if reason == nil {
+			reason = inputOrder.RejectionReason
+		}
 	}
 	now := time.Now()
-	order.CustomerID = id
 	order.CustomerName = inputOrder.CustomerName
 	order.DeliverMethod = inputOrder.DeliverMethod
 	order.DeliveryAddress = inputOrder.DeliveryAddress
@@ -125,18 +136,143 @@ func (o *OrderHandler) update(c *gin.Context) {
 	order.ModifiedAt = &now
 	order.Phone = inputOrder.Phone
 	order.RejectionReason = inputOrder.RejectionReason
-	order.Status = inputOrder.Status
-	order.TotalAmount = *inputOrder.TotalAmount
 	order.Weight = *inputOrder.Weight
 
-	if err := o.orderService.Update(order); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی سفارش"})
+	changedBy := models.OrderChangedBy(c.GetString("role"), c.GetUint64("adminId"))
+	if err := o.orderService.Save(order, inputOrder.Status, changedBy, reason); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی سفارش", "error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "سفارش با موفقیت بروزرسانی شد"})
 }
 
+func (o *OrderHandler) getStatusHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه سفارش"})
+		return
+	}
+
+	history, err := o.orderService.GetStatusHistory(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت تاریخچه وضعیت سفارش", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
+
+func (o *OrderHandler) cancel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه سفارش"})
+		return
+	}
+
+	customerId := c.GetUint64("customerId")
+	order, err := o.orderService.GetById(id)
+
+	if err != nil || order.CustomerID != customerId {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "سفارش یافت نشد"})
+		return
+	}
+
+	var inputCancel struct {
+		Reason string `form:"reason" binding:"required"`
+	}
+
+	if err := c.ShouldBind(&inputCancel); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Reason": "دلیل لغو"})
+		c.JSON(http.StatusNotAcceptable, gin.H{"message": getErrors})
+		return
+	}
+
+	changedBy := models.OrderChangedBy("Customer", customerId)
+	if err := o.refundService.Cancel(order, inputCancel.Reason, changedBy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در لغو سفارش", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "سفارش با موفقیت لغو شد"})
+}
+
+func (o *OrderHandler) refund(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه سفارش"})
+		return
+	}
+
+	order, err := o.orderService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputRefund struct {
+		Lines   []models.RefundLine `json:"lines" binding:"dive"`
+		Method  models.RefundMethod `json:"method"`
+		Reason  string              `json:"reason" binding:"required"`
+		Restock *bool               `json:"restock"`
+	}
+
+	if err := c.ShouldBindJSON(&inputRefund); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Lines": "محصولات", "Method": "روش بازپرداخت", "Reason": "دلیل بازپرداخت", "Restock": "بازگشت به انبار", "OrderProductID": "شناسه محصول سفارش", "Quantity": "تعداد"})
+		c.JSON(http.StatusNotAcceptable, gin.H{"message": getErrors})
+		return
+	}
+
+	if inputRefund.Method == "" {
+		inputRefund.Method = models.RefundMethodGateway
+	}
+	restock := inputRefund.Restock == nil || *inputRefund.Restock
+
+	refund, err := o.refundService.Refund(order, models.RefundInput{
+		Lines:     inputRefund.Lines,
+		Method:    inputRefund.Method,
+		Reason:    inputRefund.Reason,
+		Restock:   restock,
+		ChangedBy: models.OrderChangedBy(c.GetString("role"), c.GetUint64("adminId")),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بازپرداخت سفارش", "error": err.Error(), "refund": refund})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "بازپرداخت با موفقیت انجام شد", "refund": refund})
+}
+
+func (o *OrderHandler) getRefunds(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه سفارش"})
+		return
+	}
+
+	order, err := o.orderService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	refunds, err := o.refundService.GetByPayment(order.TransactionID)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت بازپرداخت ها", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"refunds": refunds})
+}
+
 func (o *OrderHandler) getByCustomer(c *gin.Context) {
 	id := c.Query("id")
 	var idUint uint64
@@ -180,12 +316,17 @@ func (o *OrderHandler) getByCustomer(c *gin.Context) {
 func (o *OrderHandler) create(c *gin.Context) {
 	customerId := c.GetUint64("customerId")
 	var inputOrder struct {
-		AddressID   uint64  `form:"address_id" binding:"required"`
-		Description *string `form:"description"`
+		AddressID      uint64       `form:"address_id" binding:"required"`
+		Description    *string      `form:"description"`
+		ShippingMethod string       `form:"shipping_method"`
+		UseWallet      bool         `form:"use_wallet"`
+		WalletAmount   *utils.Money `form:"wallet_amount" binding:"omitempty,gt=0"`
+		GiftCardCode   string       `form:"gift_card_code"`
+		LoyaltyPoints  int          `form:"loyalty_points" binding:"gte=0"`
 	}
 
 	if err := c.ShouldBind(&inputOrder); err != nil {
-		getErrors := utils.FormValidation(err.Error(), map[string]string{"AddressID": "شناسه آدرس", "Description": "توضیحات"})
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"AddressID": "شناسه آدرس", "Description": "توضیحات", "ShippingMethod": "روش ارسال", "UseWallet": "پرداخت با کیف پول", "WalletAmount": "مبلغ کیف پول", "GiftCardCode": "کد کارت هدیه", "LoyaltyPoints": "امتیاز باشگاه مشتریان"})
 		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors, "error": err.Error()})
 		return
 	}
@@ -202,29 +343,8 @@ func (o *OrderHandler) create(c *gin.Context) {
 		return
 	}
 
-	cart, err := o.cartService.GetByCustomerId(customerId)
-	if err != nil || len(cart.CartProducts) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "سبد خرید شما خالی می باشد"})
-		return
-	}
-
-	var productsId []uint64
-	var productsMap = make(map[int]int)
-	for _, val := range cart.CartProducts {
-		productsMap[int(val.ProductID)] = val.Quantity
-		productsId = append(productsId, val.ProductID)
-	}
-
-	var weight, totalAmount float64
-	products, err := o.productService.GetProductsById(productsId...)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت محصولات سبد خرید"})
-		return
-	}
-
-	for _, val := range *products {
-		weight += val.ShipmentWeight * float64(productsMap[int(val.ID)])
-		totalAmount += val.Price * float64(productsMap[int(val.ID)])
+	if inputOrder.ShippingMethod == "" {
+		inputOrder.ShippingMethod = "post"
 	}
 
 	userAgent := c.Request.UserAgent()
@@ -237,92 +357,33 @@ func (o *OrderHandler) create(c *gin.Context) {
 		deviceType = "browser"
 	}
 
-	tx := o.orderService.BeginTransaction()
-	defer tx.Rollback()
+	customerOrder, transaction, err := o.checkoutService.Checkout(models.CheckoutInput{
+		CustomerID:     customerId,
+		Address:        address,
+		Description:    inputOrder.Description,
+		Device:         deviceType,
+		Gateway:        utils.DefaultPaymentGatewayName(),
+		ShippingMethod: inputOrder.ShippingMethod,
+		UseWallet:      inputOrder.UseWallet,
+		WalletLimit:    inputOrder.WalletAmount,
+		GiftCardCode:   inputOrder.GiftCardCode,
+		LoyaltyPoints:  inputOrder.LoyaltyPoints,
+	})
 
-	transaction := models.Transaction{
-		CustomerID: customerId,
-		Type:       "default",
-		Device:     deviceType,
-		Status:     models.TransactionStatusNew,
-		Amount:     totalAmount,
-	}
-
-	if err := tx.Create(&transaction).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ساخت تراکنش"})
-		return
-	}
-
-	customerOrder := models.Order{
-		AddressID:       address.ID,
-		CustomerID:      customerId,
-		TransactionID:   transaction.ID,
-		CustomerName:    address.ReceiverName,
-		Phone:           address.Phone,
-		Description:     inputOrder.Description,
-		DeliverMethod:   "post",
-		DeliveryAddress: address.Address,
-		Status:          models.OrderStatusWaitingForIPG,
-		Weight:          weight,
-		TotalAmount:     totalAmount,
-	}
-
-	if err := tx.Create(&customerOrder).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ساخت سفارش", "error": err.Error()})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
 
-	var orderProducts []models.OrderProduct
-	for _, val := range cart.CartProducts {
-		var price float64
-		for _, value := range *products {
-			if value.ID == val.ProductID {
-				price = value.Price
-			}
-		}
-		orderProducts = append(orderProducts, models.OrderProduct{
-			OrderID:   customerOrder.ID,
-			Quantity:  val.Quantity,
-			ProductID: val.ProductID,
-			Price:     price,
-		})
-	}
-
-	zarinPay, err := utils.NewZarinpal(os.Getenv("MERCHANT_ID"), true)
-
-	if err != nil {
-		transaction.Status = models.TransactionStatusFailed
-		customerOrder.Status = models.OrderStatusFailed
-		if err := tx.Save(&transaction).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی وضعیت تراکنش", "error": err.Error()})
-			return
-		}
-		if err := tx.Save(&customerOrder).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی وضعیت سفارش", "error": err.Error()})
-			return
-		}
-		if err := tx.Commit().Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در کامیت تراکنش", "error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"message": "بخش اول : خطا در ایجاد درگاه پرداخت", "error": err.Error()})
+	if transaction.Status == models.TransactionStatusSucceed {
+		c.JSON(http.StatusCreated, gin.H{"message": "سفارش با موفقیت ثبت و از کارت هدیه یا کیف پول پرداخت شد", "orderId": customerOrder.ID})
 		return
 	}
 
-	paymentURL, authority, statusCode, err := zarinPay.NewPaymentRequest(2000000000, fmt.Sprintf("http://localhost:8080/v1/public/orders/%v", customerOrder.ID), "test", "test@test.com", "09900994735")
+	paymentURL, statusCode, err := o.paymentService.Request(customerOrder, transaction)
 	if err != nil {
-		transaction.Status = models.TransactionStatusFailed
-		customerOrder.Status = models.OrderStatusFailed
-		if err := tx.Save(&transaction).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی وضعیت تراکنش", "error": err.Error()})
-			return
-		}
-		if err := tx.Save(&customerOrder).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی وضعیت سفارش", "error": err.Error()})
-			return
-		}
-		if err := tx.Commit().Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در کامیت تراکنش", "error": err.Error()})
+		if abortErr := o.checkoutService.Abort(customerOrder, transaction, err.Error()); abortErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی وضعیت سفارش", "error": abortErr.Error()})
 			return
 		}
 
@@ -330,26 +391,11 @@ func (o *OrderHandler) create(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "مبلغ کل برای سیستم بانکی قابل قبول نیست"})
 			return
 		}
-		c.JSON(http.StatusBadRequest, gin.H{"message": "بخش دوم : خطا در ایجاد درگاه پرداخت", "error": err.Error()})
-		return
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در کامیت تراکنش", "error": err.Error()})
-		return
-	}
-
-	if err := o.orderProductService.CreateRange(orderProducts...); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ساخت سفارش محصولات"})
-		return
-	}
-
-	if err := o.cartProductService.DeleteAll(cart.ID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در حذف کردن محصولات سبد خرید"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ایجاد درگاه پرداخت", "error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "سفارش با موفقیت ثبت شد", "URL": paymentURL, "authority": authority})
+	c.JSON(http.StatusCreated, gin.H{"message": "سفارش با موفقیت ثبت شد", "URL": paymentURL, "authority": *transaction.Authority})
 }
 
 func (o *OrderHandler) paymentUpdate(c *gin.Context) {
@@ -385,16 +431,14 @@ func (o *OrderHandler) paymentUpdate(c *gin.Context) {
 		return
 	}
 
-	zarinPay, err := utils.NewZarinpal(os.Getenv("MERCHANT_ID"), true)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در اطلاعات درگاه پرداخت", "error": err.Error()})
+	if transaction.Status != models.TransactionStatusNew && transaction.Status != models.TransactionStatusInProgress {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "وضعیت این پرداخت از قبل مشخص شده است"})
 		return
 	}
 
-	verified, refID, statusCode, err := zarinPay.PaymentVerification(2000000000, inputOrder.Authority)
+	verified, refID, statusCode, err := o.paymentService.Verify(order, transaction, inputOrder.Authority, inputOrder.Status)
 	if err != nil {
-		if statusCode == 101 {
+		if statusCode == 101 || errors.Is(err, models.ErrPaymentSettled) {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "این پرداخت از قبل تایید شده است"})
 			return
 		}
@@ -402,93 +446,84 @@ func (o *OrderHandler) paymentUpdate(c *gin.Context) {
 		return
 	}
 
-	if verified {
-		order.Status = models.OrderStatusNew
-		transaction.Status = models.TransactionStatusSucceed
+	c.JSON(http.StatusAccepted, gin.H{"message": fmt.Sprintf("پرداخت شما تایید شد/نشد : %v", verified), "refID": refID})
+}
 
-		if err := o.orderService.Update(order); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی سفارش", "error": err.Error()})
-			return
-		}
+// callBackUrl is where the payment gateway sends the customer back to.
+// The payment is verified here on the server and the customer is then
+// redirected to the storefront with a signed token describing the result.
+func (o *OrderHandler) callBackUrl(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 
-		if err := o.transactionService.Update(transaction); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی تراکنش", "error": err.Error()})
-			return
-		}
-	} else {
-		order.Status = models.OrderStatusFailed
-		transaction.Status = models.TransactionStatusFailed
+	if err != nil {
+		redirectPaymentResult(c, 0, false, "")
+		return
+	}
 
-		if err := o.orderService.Update(order); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی سفارش", "error": err.Error()})
-			return
+	order, err := o.orderService.GetById(id)
+
+	if err != nil {
+		redirectPaymentResult(c, id, false, "")
+		return
+	}
+
+	transaction, err := o.transactionService.GetById(order.TransactionID)
+
+	if err != nil {
+		redirectPaymentResult(c, order.ID, false, "")
+		return
+	}
+
+	// the gateway may call back more than once, the first result wins
+	if transaction.Status != models.TransactionStatusNew && transaction.Status != models.TransactionStatusInProgress {
+		var refID string
+		if transaction.RetrievalReferenceNumber != nil {
+			refID = *transaction.RetrievalReferenceNumber
 		}
+		redirectPaymentResult(c, order.ID, transaction.Status == models.TransactionStatusSucceed, refID)
+		return
+	}
 
-		if err := o.transactionService.Update(transaction); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی تراکنش", "error": err.Error()})
+	verified, refID, _, err := o.paymentService.Verify(order, transaction, c.Query("Authority"), c.Query("Status"))
+	if errors.Is(err, models.ErrPaymentSettled) {
+		// settled meanwhile by the reconciler or the sweeper, report what they decided
+		if transaction, err = o.transactionService.GetById(order.TransactionID); err == nil && transaction.Status == models.TransactionStatusSucceed {
+			if transaction.RetrievalReferenceNumber != nil {
+				refID = *transaction.RetrievalReferenceNumber
+			}
+			redirectPaymentResult(c, order.ID, true, refID)
 			return
 		}
 	}
+	if err != nil {
+		redirectPaymentResult(c, order.ID, false, "")
+		return
+	}
 
-	c.JSON(http.StatusAccepted, gin.H{"message": fmt.Sprintf("پرداخت شما تایید شد/نشد : %v", verified), "refID": refID})
+	redirectPaymentResult(c, order.ID, verified, refID)
 }
 
-func (o *OrderHandler) callBackUrl(c *gin.Context) {
-	id := c.Param("id")
-
-	authority := c.Query("Authority")
-	status := c.Query("Status")
-
-	html := `
-		<!DOCTYPE html>
-		<html lang="fa">
-		<head>
-			<meta charset="UTF-8">
-			<meta name="viewport" content="width=device-width, initial-scale=1.0">
-			<title>صفحه دکمه</title>
-			<script>
-				window.onload = function() {
-					const id = '` + id + `';
-					const authority = '` + authority + `';
-					const status = '` + status + `';
-
-					const body = JSON.stringify({
-						authority: authority,
-						status: status
-					});
-
-					fetch('/v1/public/orders/' + id, {
-						method: 'PUT',
-						headers: {
-							'Content-Type': 'application/json'
-						},
-						body: body
-					})
-					.then(response => response.text())
-					.then(data => console.log(data))
-					.catch(error => console.error('Error:', error));
-				};
-			</script>
-			<style>
-				body {
-					display: flex;
-					justify-content: center;
-					align-items: center;
-					height: 100vh;
-					background-color: #f0f0f0;
-				}
-				button {
-					padding: 10px 20px;
-					font-size: 16px;
-				}
-			</style>
-		</head>
-		<body>
-			<button onclick="alert('دکمه کلیک شد!')">کلیک کن!</button>
-		</body>
-		</html>
-		`
-
-	c.Header("Content-Type", "text/html")
-	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+func redirectPaymentResult(c *gin.Context, orderId uint64, succeed bool, refID string) {
+	target := os.Getenv("STOREFRONT_PAYMENT_FAILURE_URL")
+	if succeed {
+		target = os.Getenv("STOREFRONT_PAYMENT_SUCCESS_URL")
+	}
+
+	token, err := utils.CreatePaymentResultToken(orderId, succeed, refID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "خطا در ساخت نتیجه پرداخت", "error": err.Error()})
+		return
+	}
+
+	redirectURL, err := url.Parse(target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "آدرس بازگشت به فروشگاه معتبر نیست", "error": err.Error()})
+		return
+	}
+
+	query := redirectURL.Query()
+	query.Set("token", token)
+	redirectURL.RawQuery = query.Encode()
+
+	c.Redirect(http.StatusFound, redirectURL.String())
 }
@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PaymentMismatchHandler struct {
+	paymentMismatchService *models.PaymentMismatchService
+}
+
+func NewPaymentMismatchHandler(db *gorm.DB) *PaymentMismatchHandler {
+	return &PaymentMismatchHandler{
+		paymentMismatchService: models.NewPaymentMismatchService(db),
+	}
+}
+
+func (p *PaymentMismatchHandler) getAll(c *gin.Context) {
+	take := c.Query("take")
+	skip := c.Query("skip")
+	takeInt, err := strconv.Atoi(take)
+	if err != nil {
+		takeInt = 10
+	}
+	skipInt, err := strconv.Atoi(skip)
+	if err != nil {
+		skipInt = 0
+	}
+
+	var isResolved *bool
+	if parsed, err := strconv.ParseBool(c.Query("isResolved")); err == nil {
+		isResolved = &parsed
+	}
+
+	mismatches, err := p.paymentMismatchService.GetAll(isResolved, takeInt, skipInt)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت مغایرت های پرداخت", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment_mismatches": mismatches})
+}
+
+func (p *PaymentMismatchHandler) update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه مغایرت پرداخت"})
+		return
+	}
+
+	mismatch, err := p.paymentMismatchService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputMismatch struct {
+		IsResolved  *bool   `form:"is_resolved" binding:"required"`
+		Description *string `form:"description"`
+	}
+
+	if err := c.ShouldBind(&inputMismatch); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"IsResolved": "رسیدگی شده", "Description": "توضیحات"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	now := time.Now()
+	mismatch.IsResolved = inputMismatch.IsResolved
+	mismatch.ModifiedAt = &now
+	if inputMismatch.Description != nil {
+		mismatch.Description = inputMismatch.Description
+	}
+
+	if err := p.paymentMismatchService.Update(mismatch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی مغایرت پرداخت"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "مغایرت پرداخت با موفقیت بروزرسانی شد"})
+}
@@ -0,0 +1,257 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ProductVariantHandler struct {
+	variantService *models.ProductVariantService
+	productService *models.ProductService
+}
+
+func NewProductVariantHandler(db *gorm.DB) *ProductVariantHandler {
+	return &ProductVariantHandler{
+		variantService: models.NewProductVariantService(db),
+		productService: models.NewProductService(db),
+	}
+}
+
+type productVariantInput struct {
+	SKU      string       `form:"sku" binding:"required"`
+	Barcode  *string      `form:"barcode"`
+	Price    *utils.Money `form:"price" binding:"omitempty,gt=0"`
+	Stock    *int         `form:"stock" binding:"required,gte=0"`
+	Weight   *float64     `form:"weight" binding:"omitempty,gt=0"`
+	IsActive *bool        `form:"is_active"`
+	ValueIDs []uint64     `form:"value_ids" binding:"required"`
+}
+
+var productVariantInputFields = map[string]string{"SKU": "کد SKU", "Barcode": "بارکد", "Price": "قیمت", "Stock": "موجودی", "Weight": "وزن", "IsActive": "وضعیت", "ValueIDs": "مقادیر ویژگی ها"}
+
+func (p *ProductVariantHandler) productId(c *gin.Context) (uint64, bool) {
+	productId, err := strconv.ParseUint(c.Param("productId"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه محصول"})
+		return 0, false
+	}
+
+	if !p.productService.IsProductById(productId) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "محصولی یافت نشد"})
+		return 0, false
+	}
+
+	return productId, true
+}
+
+func (p *ProductVariantHandler) getOptions(c *gin.Context) {
+	productId, ok := p.productId(c)
+	if !ok {
+		return
+	}
+
+	options, err := p.variantService.GetOptions(productId)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت ویژگی های محصول", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"options": options})
+}
+
+func (p *ProductVariantHandler) createOption(c *gin.Context) {
+	productId, ok := p.productId(c)
+	if !ok {
+		return
+	}
+
+	var inputOption struct {
+		Name     string   `form:"name" binding:"required"`
+		Position int      `form:"position" binding:"gte=0"`
+		Values   []string `form:"values" binding:"required,dive,required"`
+	}
+
+	if err := c.ShouldBind(&inputOption); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Name": "نام ویژگی", "Position": "ترتیب", "Values": "مقادیر"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	option := models.ProductOption{
+		ProductID: productId,
+		Name:      inputOption.Name,
+		Position:  inputOption.Position,
+	}
+	for i, value := range inputOption.Values {
+		option.Values = append(option.Values, models.ProductOptionValue{Value: value, Position: i})
+	}
+
+	if err := p.variantService.CreateOption(&option); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ذخیره ویژگی محصول", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "ویژگی محصول با موفقیت ذخیره شد", "option": option})
+}
+
+func (p *ProductVariantHandler) deleteOption(c *gin.Context) {
+	productId, ok := p.productId(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه ویژگی"})
+		return
+	}
+
+	if err := p.variantService.DeleteOption(productId, id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در حذف ویژگی محصول", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ویژگی محصول با موفقیت حذف شد"})
+}
+
+func (p *ProductVariantHandler) getAll(c *gin.Context) {
+	productId, ok := p.productId(c)
+	if !ok {
+		return
+	}
+
+	variants, err := p.variantService.GetAll(productId)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت تنوع های محصول", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"variants": variants})
+}
+
+func (p *ProductVariantHandler) create(c *gin.Context) {
+	productId, ok := p.productId(c)
+	if !ok {
+		return
+	}
+
+	var inputVariant productVariantInput
+
+	if err := c.ShouldBind(&inputVariant); err != nil {
+		getErrors := utils.FormValidation(err.Error(), productVariantInputFields)
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	variant := models.ProductVariant{
+		ProductID: productId,
+		SKU:       inputVariant.SKU,
+		Barcode:   inputVariant.Barcode,
+		Price:     inputVariant.Price,
+		Stock:     *inputVariant.Stock,
+		Weight:    inputVariant.Weight,
+		IsActive:  inputVariant.IsActive,
+	}
+
+	if err := p.variantService.Save(&variant, inputVariant.ValueIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ذخیره تنوع محصول", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "تنوع محصول با موفقیت ذخیره شد", "variant": variant})
+}
+
+func (p *ProductVariantHandler) update(c *gin.Context) {
+	productId, ok := p.productId(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه تنوع محصول"})
+		return
+	}
+
+	variant, err := p.variantService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if variant.ProductID != productId {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "شناسه تنوع محصول غیر مجاز است"})
+		return
+	}
+
+	var inputVariant productVariantInput
+
+	if err := c.ShouldBind(&inputVariant); err != nil {
+		getErrors := utils.FormValidation(err.Error(), productVariantInputFields)
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	now := time.Now()
+	variant.SKU = inputVariant.SKU
+	variant.Barcode = inputVariant.Barcode
+	variant.Price = inputVariant.Price
+	variant.Stock = *inputVariant.Stock
+	variant.Weight = inputVariant.Weight
+	if inputVariant.IsActive != nil {
+		variant.IsActive = inputVariant.IsActive
+	}
+	variant.ModifiedAt = &now
+
+	if err := p.variantService.Save(variant, inputVariant.ValueIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی تنوع محصول", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "تنوع محصول با موفقیت بروزرسانی شد"})
+}
+
+func (p *ProductVariantHandler) delete(c *gin.Context) {
+	productId, ok := p.productId(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه تنوع محصول"})
+		return
+	}
+
+	variant, err := p.variantService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if variant.ProductID != productId {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "شناسه تنوع محصول غیر مجاز است"})
+		return
+	}
+
+	if err := p.variantService.Delete(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در حذف تنوع محصول", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "تنوع محصول با موفقیت حذف شد"})
+}
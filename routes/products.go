@@ -57,15 +57,15 @@ func (p *ProductHandler) getAll(c *gin.Context) {
 		skipInt = 0
 	}
 
-	var minPrice, maxPrice float64
+	var minPrice, maxPrice utils.Money
 	if minPriceStr != "" {
-		if parsedMinPrice, err := strconv.ParseFloat(minPriceStr, 64); err == nil {
+		if parsedMinPrice, err := utils.ParseMoney(minPriceStr); err == nil {
 			minPrice = parsedMinPrice
 		}
 	}
 
 	if maxPriceStr != "" {
-		if parsedMaxPrice, err := strconv.ParseFloat(maxPriceStr, 64); err == nil {
+		if parsedMaxPrice, err := utils.ParseMoney(maxPriceStr); err == nil {
 			maxPrice = parsedMaxPrice
 		}
 	}
@@ -111,27 +111,75 @@ func (p *ProductHandler) getAllActive(c *gin.Context) {
 		skipInt = 0
 	}
 
-	var minPrice, maxPrice float64
+	var minPrice, maxPrice utils.Money
 	if minPriceStr != "" {
-		if parsedMinPrice, err := strconv.ParseFloat(minPriceStr, 64); err == nil {
+		if parsedMinPrice, err := utils.ParseMoney(minPriceStr); err == nil {
 			minPrice = parsedMinPrice
 		}
 	}
 
 	if maxPriceStr != "" {
-		if parsedMaxPrice, err := strconv.ParseFloat(maxPriceStr, 64); err == nil {
+		if parsedMaxPrice, err := utils.ParseMoney(maxPriceStr); err == nil {
 			maxPrice = parsedMaxPrice
 		}
 	}
 
-	products, err := p.productService.GetAllActive(productIdUint, categoryIdUint, minPrice, maxPrice, name, sortBy, order, takeInt, skipInt)
+	products, facets, err := p.productService.GetAllActive(productIdUint, categoryIdUint, minPrice, maxPrice, name, specFilters(c), sortBy, order, takeInt, skipInt)
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت محصولات", "error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusAccepted, gin.H{"products": products})
+	c.JSON(http.StatusAccepted, gin.H{"products": products, "facets": facets})
+}
+
+// specFilters reads the spec[<key>]=<value> query parameters. A key given
+// more than once matches products with any of its values.
+func specFilters(c *gin.Context) map[string][]string {
+	specs := make(map[string][]string)
+	for param, values := range c.Request.URL.Query() {
+		key, ok := strings.CutPrefix(param, "spec[")
+		if !ok || !strings.HasSuffix(key, "]") {
+			continue
+		}
+		key = strings.TrimSuffix(key, "]")
+		for _, value := range values {
+			if value = strings.TrimSpace(value); value != "" {
+				specs[key] = append(specs[key], value)
+			}
+		}
+	}
+	return specs
+}
+
+func (p *ProductHandler) search(c *gin.Context) {
+	q := c.Query("q")
+	if strings.TrimSpace(q) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "عبارت جستجو را وارد کنید"})
+		return
+	}
+	var categoryId uint64
+	if parseId, err := strconv.ParseUint(c.Query("categoryId"), 10, 64); err == nil {
+		categoryId = parseId
+	}
+	take, err := strconv.Atoi(c.Query("take"))
+	if err != nil {
+		take = 10
+	}
+	skip, err := strconv.Atoi(c.Query("skip"))
+	if err != nil {
+		skip = 0
+	}
+
+	products, err := p.productService.Search(q, categoryId, take, skip)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در جستجوی محصولات", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"products": products})
 }
 
 func (p *ProductHandler) create(c *gin.Context) {
@@ -148,11 +196,12 @@ func (p *ProductHandler) create(c *gin.Context) {
 
 	var inputProduct struct {
 		Name           string                `json:"name" form:"name" binding:"required"`
-		Price          float64               `json:"price" form:"price" binding:"required"`
+		Price          utils.Money           `json:"price" form:"price" binding:"required"`
 		Stock          int                   `json:"stock" form:"stock" binding:"required"`
 		ShipmentWeight *float64              `json:"shipment_weight" form:"shipment_weight" binding:"required,gt=0"`
 		Description    *string               `json:"description" form:"description"`
 		File           *multipart.FileHeader `json:"file" form:"file" binding:"required"`
+		IsGiftCard     *bool                 `json:"is_gift_card" form:"is_gift_card"`
 	}
 
 	if err := c.ShouldBind(&inputProduct); err != nil {
@@ -174,6 +223,7 @@ func (p *ProductHandler) create(c *gin.Context) {
 		Stock:          inputProduct.Stock,
 		Thumbnail:      *imageLocation,
 		ShipmentWeight: *inputProduct.ShipmentWeight,
+		IsGiftCard:     inputProduct.IsGiftCard,
 		CategoryID:     id,
 	}
 
@@ -217,11 +267,12 @@ func (p *ProductHandler) update(c *gin.Context) {
 	var inputProduct struct {
 		Name           string                `json:"name" form:"name" binding:"required"`
 		Description    *string               `json:"description" form:"description"`
-		Price          float64               `json:"price" form:"price" binding:"required"`
+		Price          utils.Money           `json:"price" form:"price" binding:"required"`
 		Stock          *int                  `json:"stock" form:"stock" binding:"required"`
 		ShipmentWeight *float64              `json:"shipment_weight" form:"shipment_weight" binding:"required,gt=0"`
 		Thumbnail      *multipart.FileHeader `json:"file" form:"file"`
 		IsActive       *bool                 `json:"is_active" form:"is_active"`
+		IsGiftCard     *bool                 `json:"is_gift_card" form:"is_gift_card"`
 	}
 
 	if err := c.ShouldBind(&inputProduct); err != nil {
@@ -262,6 +313,9 @@ func (p *ProductHandler) update(c *gin.Context) {
 	if inputProduct.IsActive != nil {
 		product.IsActive = inputProduct.IsActive
 	}
+	if inputProduct.IsGiftCard != nil {
+		product.IsGiftCard = inputProduct.IsGiftCard
+	}
 
 	if err = p.productService.Update(product); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی محصول"})
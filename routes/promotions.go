@@ -0,0 +1,147 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PromotionHandler struct {
+	promotionService *models.PromotionService
+}
+
+func NewPromotionHandler(db *gorm.DB) *PromotionHandler {
+	return &PromotionHandler{
+		promotionService: models.NewPromotionService(db),
+	}
+}
+
+type promotionInput struct {
+	Name        string               `json:"name" binding:"required"`
+	Type        models.PromotionType `json:"type" binding:"required"`
+	CategoryID  *uint64              `json:"category_id"`
+	ProductID   *uint64              `json:"product_id"`
+	BuyQuantity int                  `json:"buy_quantity" binding:"gte=0"`
+	GetQuantity int                  `json:"get_quantity" binding:"gte=0"`
+	Percentage  float64              `json:"percentage" binding:"gte=0,lte=100"`
+	MinBasket   *utils.Money         `json:"min_basket" binding:"omitempty,gte=0"`
+	Weekdays    string               `json:"weekdays"`
+	StartsAt    *time.Time           `json:"starts_at"`
+	ExpiresAt   *time.Time           `json:"expires_at"`
+	Priority    int                  `json:"priority"`
+	IsActive    *bool                `json:"is_active"`
+	Tiers       []struct {
+		MinAmount  utils.Money `json:"min_amount" binding:"gte=0"`
+		Percentage float64     `json:"percentage" binding:"gt=0,lte=100"`
+	} `json:"tiers" binding:"dive"`
+}
+
+var promotionInputFields = map[string]string{"Name": "نام", "Type": "نوع", "CategoryID": "دسته بندی", "ProductID": "محصول", "BuyQuantity": "تعداد خرید", "GetQuantity": "تعداد رایگان", "Percentage": "درصد تخفیف", "MinBasket": "حداقل مبلغ خرید", "Weekdays": "روزهای هفته", "StartsAt": "زمان شروع", "ExpiresAt": "زمان پایان", "Priority": "اولویت", "IsActive": "فعال", "Tiers": "پله ها", "MinAmount": "حداقل مبلغ"}
+
+func (input *promotionInput) fill(promotion *models.Promotion) {
+	promotion.Name = input.Name
+	promotion.Type = input.Type
+	promotion.CategoryID = input.CategoryID
+	promotion.ProductID = input.ProductID
+	promotion.BuyQuantity = input.BuyQuantity
+	promotion.GetQuantity = input.GetQuantity
+	promotion.Percentage = input.Percentage
+	promotion.MinBasket = input.MinBasket
+	promotion.Weekdays = input.Weekdays
+	promotion.StartsAt = input.StartsAt
+	promotion.ExpiresAt = input.ExpiresAt
+	promotion.Priority = input.Priority
+	if input.IsActive != nil {
+		promotion.IsActive = input.IsActive
+	}
+
+	promotion.Tiers = nil
+	for _, tier := range input.Tiers {
+		promotion.Tiers = append(promotion.Tiers, models.PromotionTier{
+			MinAmount:  tier.MinAmount,
+			Percentage: tier.Percentage,
+		})
+	}
+}
+
+func (p *PromotionHandler) getAll(c *gin.Context) {
+	take := c.Query("take")
+	skip := c.Query("skip")
+	takeInt, err := strconv.Atoi(take)
+	if err != nil {
+		takeInt = 10
+	}
+	skipInt, err := strconv.Atoi(skip)
+	if err != nil {
+		skipInt = 0
+	}
+
+	promotions, err := p.promotionService.GetAll(takeInt, skipInt)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت تخفیف های خودکار", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"promotions": promotions})
+}
+
+func (p *PromotionHandler) create(c *gin.Context) {
+	var inputPromotion promotionInput
+
+	if err := c.ShouldBindJSON(&inputPromotion); err != nil {
+		getErrors := utils.FormValidation(err.Error(), promotionInputFields)
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	var promotion models.Promotion
+	inputPromotion.fill(&promotion)
+
+	if err := p.promotionService.Save(&promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ذخیره تخفیف خودکار", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "تخفیف خودکار با موفقیت ذخیره شد", "promotion": promotion})
+}
+
+func (p *PromotionHandler) update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه تخفیف خودکار"})
+		return
+	}
+
+	promotion, err := p.promotionService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputPromotion promotionInput
+
+	if err := c.ShouldBindJSON(&inputPromotion); err != nil {
+		getErrors := utils.FormValidation(err.Error(), promotionInputFields)
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	now := time.Now()
+	inputPromotion.fill(promotion)
+	promotion.ModifiedAt = &now
+
+	if err := p.promotionService.Save(promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی تخفیف خودکار", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "تخفیف خودکار با موفقیت بروزرسانی شد"})
+}
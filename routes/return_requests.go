@@ -0,0 +1,235 @@
+package routes
+
+import (
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReturnRequestHandler struct {
+	returnRequestService *models.ReturnRequestService
+	orderService         *models.OrderService
+}
+
+func NewReturnRequestHandler(db *gorm.DB) *ReturnRequestHandler {
+	return &ReturnRequestHandler{
+		returnRequestService: models.NewReturnRequestService(db),
+		orderService:         models.NewOrderService(db),
+	}
+}
+
+func (r *ReturnRequestHandler) getAll(c *gin.Context) {
+	take := c.Query("take")
+	skip := c.Query("skip")
+	takeInt, err := strconv.Atoi(take)
+	if err != nil {
+		takeInt = 10
+	}
+	skipInt, err := strconv.Atoi(skip)
+	if err != nil {
+		skipInt = 0
+	}
+
+	var customerId uint64
+	if parsedId, err := strconv.ParseUint(c.Query("customerId"), 10, 64); err == nil {
+		customerId = parsedId
+	}
+	var orderId uint64
+	if parsedId, err := strconv.ParseUint(c.Query("orderId"), 10, 64); err == nil {
+		orderId = parsedId
+	}
+
+	returnRequests, err := r.returnRequestService.GetAll(customerId, orderId, models.ReturnRequestStatus(c.Query("status")), takeInt, skipInt)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت درخواست های مرجوعی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"return_requests": returnRequests})
+}
+
+func (r *ReturnRequestHandler) getByCustomer(c *gin.Context) {
+	take := c.Query("take")
+	skip := c.Query("skip")
+	takeInt, err := strconv.Atoi(take)
+	if err != nil {
+		takeInt = 10
+	}
+	skipInt, err := strconv.Atoi(skip)
+	if err != nil {
+		skipInt = 0
+	}
+
+	var orderId uint64
+	if parsedId, err := strconv.ParseUint(c.Query("orderId"), 10, 64); err == nil {
+		orderId = parsedId
+	}
+
+	returnRequests, err := r.returnRequestService.GetAll(c.GetUint64("customerId"), orderId, models.ReturnRequestStatus(c.Query("status")), takeInt, skipInt)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت درخواست های مرجوعی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"return_requests": returnRequests})
+}
+
+func (r *ReturnRequestHandler) create(c *gin.Context) {
+	customerId := c.GetUint64("customerId")
+
+	var inputReturnRequest struct {
+		OrderID         uint64                  `form:"order_id" binding:"required"`
+		OrderProductIDs []uint64                `form:"order_product_id" binding:"required,min=1"`
+		Quantities      []int                   `form:"quantity" binding:"required,min=1,dive,gt=0"`
+		Reason          string                  `form:"reason" binding:"required"`
+		Resolution      models.ReturnResolution `form:"resolution" binding:"required"`
+		Images          []*multipart.FileHeader `form:"images"`
+	}
+
+	if err := c.ShouldBind(&inputReturnRequest); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"OrderID": "شناسه سفارش", "OrderProductIDs": "شناسه محصولات سفارش", "Quantities": "تعداد", "Reason": "دلیل مرجوعی", "Resolution": "درخواست مشتری", "Images": "تصاویر"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors, "error": err.Error()})
+		return
+	}
+
+	if len(inputReturnRequest.OrderProductIDs) != len(inputReturnRequest.Quantities) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "تعداد هر محصول مرجوعی را وارد کنید"})
+		return
+	}
+
+	if !inputReturnRequest.Resolution.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "درخواست مشتری نامعتبر است"})
+		return
+	}
+
+	order, err := r.orderService.GetById(inputReturnRequest.OrderID)
+
+	if err != nil || order.CustomerID != customerId {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "سفارش یافت نشد"})
+		return
+	}
+
+	returnRequest := models.ReturnRequest{
+		Reason:     inputReturnRequest.Reason,
+		Resolution: inputReturnRequest.Resolution,
+	}
+	for i, orderProductId := range inputReturnRequest.OrderProductIDs {
+		returnRequest.Lines = append(returnRequest.Lines, models.ReturnRequestLine{
+			OrderProductID: orderProductId,
+			Quantity:       inputReturnRequest.Quantities[i],
+		})
+	}
+
+	// images are only uploaded for a request that can be made
+	if err := r.returnRequestService.Validate(order, &returnRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ثبت درخواست مرجوعی", "error": err.Error()})
+		return
+	}
+
+	for _, image := range inputReturnRequest.Images {
+		imageLocation, err := utils.AddImageToServer(c, "returns", "images", image)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		returnRequest.Images = append(returnRequest.Images, models.ReturnRequestImage{Image: *imageLocation})
+	}
+
+	if err := r.returnRequestService.Create(order, &returnRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ثبت درخواست مرجوعی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "درخواست مرجوعی با موفقیت ثبت شد", "return_request": returnRequest})
+}
+
+func (r *ReturnRequestHandler) update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه درخواست مرجوعی"})
+		return
+	}
+
+	returnRequest, err := r.returnRequestService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputReturnRequest struct {
+		Status          models.ReturnRequestStatus `form:"status" binding:"required"`
+		RejectionReason *string                    `form:"rejection_reason"`
+	}
+
+	if err := c.ShouldBind(&inputReturnRequest); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Status": "وضعیت", "RejectionReason": "دلیل رد شدن"})
+		c.JSON(http.StatusNotAcceptable, gin.H{"message": getErrors})
+		return
+	}
+
+	if err := r.returnRequestService.ChangeStatus(returnRequest, inputReturnRequest.Status, inputReturnRequest.RejectionReason); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی درخواست مرجوعی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "درخواست مرجوعی با موفقیت بروزرسانی شد"})
+}
+
+func (r *ReturnRequestHandler) refund(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه درخواست مرجوعی"})
+		return
+	}
+
+	returnRequest, err := r.returnRequestService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	order, err := r.orderService.GetById(returnRequest.OrderID)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputRefund struct {
+		Method  models.RefundMethod `form:"method"`
+		Restock *bool               `form:"restock"`
+	}
+
+	if err := c.ShouldBind(&inputRefund); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Method": "روش بازپرداخت", "Restock": "بازگشت به انبار"})
+		c.JSON(http.StatusNotAcceptable, gin.H{"message": getErrors})
+		return
+	}
+
+	if inputRefund.Method == "" {
+		inputRefund.Method = models.RefundMethodGateway
+	}
+	restock := inputRefund.Restock == nil || *inputRefund.Restock
+
+	changedBy := models.OrderChangedBy(c.GetString("role"), c.GetUint64("adminId"))
+	refund, err := r.returnRequestService.Refund(returnRequest, order, inputRefund.Method, restock, changedBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بازپرداخت درخواست مرجوعی", "error": err.Error(), "refund": refund})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "بازپرداخت درخواست مرجوعی با موفقیت انجام شد", "refund": refund})
+}
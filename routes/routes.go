@@ -22,12 +22,26 @@ func RegisterRouter(server *gin.Engine, db *gorm.DB) {
 	imageProductHandler := NewImageProductHandler(db)
 	specificationHandler := NewSpecificationHandler(db)
 	compareProductHandler := NewCompareProductHandler(db)
-
-	versionOne(server, superAdminHandler, adminHandler, authHandler, usersHandler, categoryHandler, productHandler, cartHandler, cartProductHandler, orderHandler, addressHandler, imageProductHandler, specificationHandler, compareProductHandler)
+	paymentMismatchHandler := NewPaymentMismatchHandler(db)
+	transactionHandler := NewTransactionHandler(db)
+	returnRequestHandler := NewReturnRequestHandler(db)
+	shipmentHandler := NewShipmentHandler(db)
+	shippingMethodHandler := NewShippingMethodHandler(db)
+	couponHandler := NewCouponHandler(db)
+	promotionHandler := NewPromotionHandler(db)
+	walletHandler := NewWalletHandler(db)
+	giftCardHandler := NewGiftCardHandler(db)
+	loyaltyHandler := NewLoyaltyHandler(db)
+	taxClassHandler := NewTaxClassHandler(db)
+	productVariantHandler := NewProductVariantHandler(db)
+	specificationFilterHandler := NewSpecificationFilterHandler(db)
+	specificationAttributeHandler := NewSpecificationAttributeHandler(db)
+
+	versionOne(server, superAdminHandler, adminHandler, authHandler, usersHandler, categoryHandler, productHandler, cartHandler, cartProductHandler, orderHandler, addressHandler, imageProductHandler, specificationHandler, compareProductHandler, paymentMismatchHandler, transactionHandler, returnRequestHandler, shipmentHandler, shippingMethodHandler, couponHandler, promotionHandler, walletHandler, giftCardHandler, loyaltyHandler, taxClassHandler, productVariantHandler, specificationFilterHandler, specificationAttributeHandler)
 	versionTwo(server)
 }
 
-func versionOne(server *gin.Engine, superAdminHandler *SuperAdminHandler, adminHandler *AdminHandler, authHandler *AuthHandler, usersHandler *CustomerHandler, categoryHandler *CategoryHandler, productHandler *ProductHandler, cartHandler *CartHandler, cartProductHandler *CartProductHandler, orderHandler *OrderHandler, addressHandler *AddressHandler, imageProductHandler *ImageProductHandler, specificationHandler *SpecificationHandler, compareProductHandler *CompareProductHandler) {
+func versionOne(server *gin.Engine, superAdminHandler *SuperAdminHandler, adminHandler *AdminHandler, authHandler *AuthHandler, usersHandler *CustomerHandler, categoryHandler *CategoryHandler, productHandler *ProductHandler, cartHandler *CartHandler, cartProductHandler *CartProductHandler, orderHandler *OrderHandler, addressHandler *AddressHandler, imageProductHandler *ImageProductHandler, specificationHandler *SpecificationHandler, compareProductHandler *CompareProductHandler, paymentMismatchHandler *PaymentMismatchHandler, transactionHandler *TransactionHandler, returnRequestHandler *ReturnRequestHandler, shipmentHandler *ShipmentHandler, shippingMethodHandler *ShippingMethodHandler, couponHandler *CouponHandler, promotionHandler *PromotionHandler, walletHandler *WalletHandler, giftCardHandler *GiftCardHandler, loyaltyHandler *LoyaltyHandler, taxClassHandler *TaxClassHandler, productVariantHandler *ProductVariantHandler, specificationFilterHandler *SpecificationFilterHandler, specificationAttributeHandler *SpecificationAttributeHandler) {
 	mainGroup := server.Group("/v1")
 
 	publicGroup := mainGroup.Group("/public")
@@ -39,7 +53,8 @@ func versionOne(server *gin.Engine, superAdminHandler *SuperAdminHandler, adminH
 	publicGroup.GET("/customers", usersHandler.getMe)
 	publicGroup.GET("/categories", categoryHandler.getAllActive)
 	publicGroup.GET("/products", productHandler.getAllActive)
-	publicGroup.PUT("/orders/:id", orderHandler.paymentUpdate)
+	publicGroup.GET("/products/search", productHandler.search)
+	publicGroup.PUT("/orders/:id", middleware.Idempotency, orderHandler.paymentUpdate)
 	publicGroup.GET("/orders/:id",orderHandler.callBackUrl)
 
 	restrictedGroup := mainGroup.Group("/restricted")
@@ -47,15 +62,25 @@ func versionOne(server *gin.Engine, superAdminHandler *SuperAdminHandler, adminH
 
 	// Restericted : Carts
 	restrictedGroup.GET("/carts", cartHandler.getAll)
-	restrictedGroup.DELETE("/carts/:cartId", cartProductHandler.deleteAll)
+	restrictedGroup.POST("/carts/coupon", middleware.Idempotency, cartHandler.applyCoupon)
+	restrictedGroup.DELETE("/carts/coupon", cartHandler.removeCoupon)
+	restrictedGroup.DELETE("/carts/:cartId", middleware.Idempotency, cartProductHandler.deleteAll)
 
 	// Restericted : Cart Products
-	restrictedGroup.POST("/cart-products", cartProductHandler.create)
-	restrictedGroup.DELETE("/cart-products/:cartProductId", cartProductHandler.delete)
+	restrictedGroup.POST("/cart-products", middleware.Idempotency, cartProductHandler.create)
+	restrictedGroup.DELETE("/cart-products/:cartProductId", middleware.Idempotency, cartProductHandler.delete)
 
 	// Restericted : Orders
 	restrictedGroup.GET("/orders", orderHandler.getByCustomer)
-	restrictedGroup.POST("/orders", orderHandler.create)
+	restrictedGroup.POST("/orders", middleware.Idempotency, orderHandler.create)
+	restrictedGroup.POST("/orders/:id/cancel", middleware.Idempotency, orderHandler.cancel)
+	restrictedGroup.GET("/shipping/quote", shippingMethodHandler.quote)
+	restrictedGroup.GET("/wallet", walletHandler.getByCustomer)
+	restrictedGroup.GET("/loyalty", loyaltyHandler.getByCustomer)
+	restrictedGroup.GET("/gift-cards", giftCardHandler.getPurchased)
+	restrictedGroup.GET("/gift-cards/:code", giftCardHandler.check)
+	restrictedGroup.GET("/returns", returnRequestHandler.getByCustomer)
+	restrictedGroup.POST("/returns", middleware.Idempotency, returnRequestHandler.create)
 
 	// Restericted : Addresses
 	restrictedGroup.GET("/addresses", addressHandler.getAllActive)
@@ -70,6 +95,8 @@ func versionOne(server *gin.Engine, superAdminHandler *SuperAdminHandler, adminH
 	superAdminGroup.GET("admins", adminHandler.getAll)
 	superAdminGroup.PUT("admins/:id", adminHandler.update)
 	superAdminGroup.DELETE("admins/:id", adminHandler.delete)
+	superAdminGroup.GET("payment-mismatches", paymentMismatchHandler.getAll)
+	superAdminGroup.PUT("payment-mismatches/:id", paymentMismatchHandler.update)
 
 	adminGroup := mainGroup.Group("/limited/")
 	adminGroup.Use(middleware.AdminAccess)
@@ -89,9 +116,59 @@ func versionOne(server *gin.Engine, superAdminHandler *SuperAdminHandler, adminH
 	adminGroup.PUT("products/:productId/specifications/:id", specificationHandler.update)
 	adminGroup.DELETE("products/:productId/specifications/:id", specificationHandler.delete)
 
+	/// Specification Filters
+	adminGroup.GET("specification-filters", specificationFilterHandler.getAll)
+	adminGroup.POST("specification-filters", specificationFilterHandler.create)
+	adminGroup.PUT("specification-filters/:id", specificationFilterHandler.update)
+	adminGroup.DELETE("specification-filters/:id", specificationFilterHandler.delete)
+
+	/// Product Variants
+	adminGroup.GET("products/:productId/options", productVariantHandler.getOptions)
+	adminGroup.POST("products/:productId/options", productVariantHandler.createOption)
+	adminGroup.DELETE("products/:productId/options/:id", productVariantHandler.deleteOption)
+	adminGroup.GET("products/:productId/variants", productVariantHandler.getAll)
+	adminGroup.POST("products/:productId/variants", productVariantHandler.create)
+	adminGroup.PUT("products/:productId/variants/:id", productVariantHandler.update)
+	adminGroup.DELETE("products/:productId/variants/:id", productVariantHandler.delete)
+
 	/// Orders
 	adminGroup.GET("orders", orderHandler.getAll)
 	adminGroup.PUT("orders/:id", orderHandler.update)
+	adminGroup.GET("orders/:id/history", orderHandler.getStatusHistory)
+	adminGroup.GET("orders/:id/refunds", orderHandler.getRefunds)
+	adminGroup.POST("orders/:id/refunds", orderHandler.refund)
+	adminGroup.GET("orders/:id/shipments", shipmentHandler.getByOrder)
+	adminGroup.POST("orders/:id/shipments", shipmentHandler.create)
+	adminGroup.PUT("shipments/:id", shipmentHandler.update)
+	adminGroup.GET("shipping-methods", shippingMethodHandler.getAll)
+	adminGroup.POST("shipping-methods", shippingMethodHandler.create)
+	adminGroup.PUT("shipping-methods/:id", shippingMethodHandler.update)
+	adminGroup.PUT("shipping-methods/:id/rates", shippingMethodHandler.setRates)
+	adminGroup.GET("customers/:id/wallet", walletHandler.get)
+	adminGroup.POST("customers/:id/wallet", walletHandler.adjust)
+	adminGroup.GET("customers/:id/loyalty", loyaltyHandler.get)
+	adminGroup.POST("customers/:id/loyalty", loyaltyHandler.adjust)
+	adminGroup.GET("loyalty/program", loyaltyHandler.getProgram)
+	adminGroup.PUT("loyalty/program", loyaltyHandler.updateProgram)
+	adminGroup.GET("tax-classes", taxClassHandler.getAll)
+	adminGroup.POST("tax-classes", taxClassHandler.create)
+	adminGroup.PUT("tax-classes/:id", taxClassHandler.update)
+	adminGroup.DELETE("tax-classes/:id", taxClassHandler.delete)
+	adminGroup.GET("gift-cards", giftCardHandler.getAll)
+	adminGroup.POST("gift-cards", giftCardHandler.create)
+	adminGroup.PUT("gift-cards/:id", giftCardHandler.update)
+	adminGroup.GET("coupons", couponHandler.getAll)
+	adminGroup.POST("coupons", couponHandler.create)
+	adminGroup.PUT("coupons/:id", couponHandler.update)
+	adminGroup.GET("promotions", promotionHandler.getAll)
+	adminGroup.POST("promotions", promotionHandler.create)
+	adminGroup.PUT("promotions/:id", promotionHandler.update)
+	adminGroup.GET("returns", returnRequestHandler.getAll)
+	adminGroup.PUT("returns/:id", returnRequestHandler.update)
+	adminGroup.POST("returns/:id/refund", returnRequestHandler.refund)
+
+	/// Transactions
+	adminGroup.GET("transactions", transactionHandler.getAll)
 
 	/// Compare Products
 	adminGroup.GET("products/:productId/compare-products", compareProductHandler.getAll)
@@ -110,6 +187,13 @@ func versionOne(server *gin.Engine, superAdminHandler *SuperAdminHandler, adminH
 	subAdminGroup.POST("/products", productHandler.create)
 	subAdminGroup.PUT("/products/:productId", productHandler.update)
 	subAdminGroup.DELETE("/products/:productId", productHandler.delete)
+
+	/// Specification Attributes
+	subAdminGroup.GET("/attributes", specificationAttributeHandler.getAll)
+	subAdminGroup.POST("/attributes", specificationAttributeHandler.create)
+	subAdminGroup.POST("/attributes/backfill", specificationAttributeHandler.backfill)
+	subAdminGroup.PUT("/attributes/:id", specificationAttributeHandler.update)
+	subAdminGroup.DELETE("/attributes/:id", specificationAttributeHandler.delete)
 }
 
 func versionTwo(server *gin.Engine) {
@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ShipmentHandler struct {
+	shipmentService *models.ShipmentService
+	orderService    *models.OrderService
+}
+
+func NewShipmentHandler(db *gorm.DB) *ShipmentHandler {
+	return &ShipmentHandler{
+		shipmentService: models.NewShipmentService(db),
+		orderService:    models.NewOrderService(db),
+	}
+}
+
+func (s *ShipmentHandler) getByOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه سفارش"})
+		return
+	}
+
+	shipments, err := s.shipmentService.GetByOrderId(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت مرسوله ها", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shipments": shipments})
+}
+
+func (s *ShipmentHandler) create(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه سفارش"})
+		return
+	}
+
+	order, err := s.orderService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputShipment struct {
+		Carrier      string  `json:"carrier" binding:"required"`
+		TrackingCode *string `json:"tracking_code"`
+		Items        []struct {
+			OrderProductID uint64 `json:"order_product_id" binding:"required"`
+			Quantity       int    `json:"quantity" binding:"required,gt=0"`
+		} `json:"items" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&inputShipment); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Carrier": "شرکت حمل", "TrackingCode": "کد رهگیری", "Items": "محصولات", "OrderProductID": "شناسه محصول سفارش", "Quantity": "تعداد"})
+		c.JSON(http.StatusNotAcceptable, gin.H{"message": getErrors})
+		return
+	}
+
+	shipment := models.Shipment{
+		Carrier:      inputShipment.Carrier,
+		TrackingCode: inputShipment.TrackingCode,
+	}
+	for _, item := range inputShipment.Items {
+		shipment.Items = append(shipment.Items, models.ShipmentItem{
+			OrderProductID: item.OrderProductID,
+			Quantity:       item.Quantity,
+		})
+	}
+
+	if err := s.shipmentService.Create(order, &shipment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ثبت مرسوله", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "مرسوله با موفقیت ثبت شد", "shipment": shipment})
+}
+
+func (s *ShipmentHandler) update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه مرسوله"})
+		return
+	}
+
+	shipment, err := s.shipmentService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputShipment struct {
+		Status       models.ShipmentStatus `form:"status" binding:"required"`
+		TrackingCode *string               `form:"tracking_code"`
+	}
+
+	if err := c.ShouldBind(&inputShipment); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Status": "وضعیت", "TrackingCode": "کد رهگیری"})
+		c.JSON(http.StatusNotAcceptable, gin.H{"message": getErrors})
+		return
+	}
+
+	changedBy := models.OrderChangedBy(c.GetString("role"), c.GetUint64("adminId"))
+	if err := s.shipmentService.ChangeStatus(shipment, inputShipment.Status, inputShipment.TrackingCode, changedBy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی مرسوله", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "مرسوله با موفقیت بروزرسانی شد", "shipment": shipment})
+}
@@ -0,0 +1,182 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ShippingMethodHandler struct {
+	shippingService *models.ShippingService
+	addressService  *models.AddressService
+}
+
+func NewShippingMethodHandler(db *gorm.DB) *ShippingMethodHandler {
+	return &ShippingMethodHandler{
+		shippingService: models.NewShippingService(db),
+		addressService:  models.NewAddressService(db),
+	}
+}
+
+func (s *ShippingMethodHandler) getAll(c *gin.Context) {
+	methods, err := s.shippingService.GetAll(false)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت روش های ارسال", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shipping_methods": methods})
+}
+
+func (s *ShippingMethodHandler) create(c *gin.Context) {
+	var inputMethod struct {
+		Code                  string       `form:"code" binding:"required"`
+		Name                  string       `form:"name" binding:"required"`
+		Calculator            string       `form:"calculator" binding:"required"`
+		FreeShippingThreshold *utils.Money `form:"free_shipping_threshold" binding:"omitempty,gte=0"`
+	}
+
+	if err := c.ShouldBind(&inputMethod); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Code": "کد", "Name": "نام", "Calculator": "محاسبه گر", "FreeShippingThreshold": "حداقل خرید ارسال رایگان"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	method := models.ShippingMethod{
+		Code:                  inputMethod.Code,
+		Name:                  inputMethod.Name,
+		Calculator:            inputMethod.Calculator,
+		FreeShippingThreshold: inputMethod.FreeShippingThreshold,
+	}
+
+	if err := s.shippingService.Create(&method); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ذخیره روش ارسال", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "روش ارسال با موفقیت ذخیره شد", "shipping_method": method})
+}
+
+func (s *ShippingMethodHandler) update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه روش ارسال"})
+		return
+	}
+
+	method, err := s.shippingService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputMethod struct {
+		Name                  string       `form:"name" binding:"required"`
+		Calculator            string       `form:"calculator" binding:"required"`
+		FreeShippingThreshold *utils.Money `form:"free_shipping_threshold" binding:"omitempty,gte=0"`
+		IsActive              *bool        `form:"is_active" binding:"required"`
+	}
+
+	if err := c.ShouldBind(&inputMethod); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Name": "نام", "Calculator": "محاسبه گر", "FreeShippingThreshold": "حداقل خرید ارسال رایگان", "IsActive": "فعال"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	now := time.Now()
+	method.Name = inputMethod.Name
+	method.Calculator = inputMethod.Calculator
+	method.FreeShippingThreshold = inputMethod.FreeShippingThreshold
+	method.IsActive = inputMethod.IsActive
+	method.ModifiedAt = &now
+
+	if err := s.shippingService.Update(method); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی روش ارسال", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "روش ارسال با موفقیت بروزرسانی شد"})
+}
+
+func (s *ShippingMethodHandler) setRates(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه روش ارسال"})
+		return
+	}
+
+	method, err := s.shippingService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputRates struct {
+		Rates []struct {
+			Zone      string       `json:"zone" binding:"omitempty,oneof=capital central remote"`
+			MinWeight float64      `json:"min_weight" binding:"gte=0"`
+			MaxWeight *float64     `json:"max_weight" binding:"omitempty,gtfield=MinWeight"`
+			Price     *utils.Money `json:"price" binding:"required,gte=0"`
+		} `json:"rates" binding:"dive"`
+	}
+
+	if err := c.ShouldBindJSON(&inputRates); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Rates": "نرخ ها", "Zone": "منطقه", "MinWeight": "حداقل وزن", "MaxWeight": "حداکثر وزن", "Price": "هزینه"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	var rates []models.ShippingRate
+	for _, rate := range inputRates.Rates {
+		rates = append(rates, models.ShippingRate{
+			Zone:      rate.Zone,
+			MinWeight: rate.MinWeight,
+			MaxWeight: rate.MaxWeight,
+			Price:     *rate.Price,
+		})
+	}
+
+	if err := s.shippingService.SetRates(method, rates); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ذخیره نرخ های ارسال", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "نرخ های ارسال با موفقیت ذخیره شد", "shipping_method": method})
+}
+
+// quote prices the customer's cart with every active delivery method, for
+// the address given by addressId or else for province.
+func (s *ShippingMethodHandler) quote(c *gin.Context) {
+	customerId := c.GetUint64("customerId")
+	province := c.Query("province")
+
+	if addressId, err := strconv.ParseUint(c.Query("addressId"), 10, 64); err == nil {
+		address, err := s.addressService.GetById(addressId)
+
+		if err != nil || address.CustomerID != customerId {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "آدرس موردنظر یافت نشد"})
+			return
+		}
+
+		province = address.Province
+	}
+
+	parcel, quotes, err := s.shippingService.QuoteCart(customerId, province)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در محاسبه هزینه ارسال", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"weight": parcel.Weight, "subtotal": parcel.Subtotal, "zone": models.ShippingZone(province), "quotes": quotes})
+}
@@ -0,0 +1,193 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SpecificationAttributeHandler struct {
+	attributeService *models.SpecificationAttributeService
+	categoryService  *models.CategoryService
+}
+
+func NewSpecificationAttributeHandler(db *gorm.DB) *SpecificationAttributeHandler {
+	return &SpecificationAttributeHandler{
+		attributeService: models.NewSpecificationAttributeService(db),
+		categoryService:  models.NewCategoryService(db),
+	}
+}
+
+type specificationAttributeInput struct {
+	Key      string                            `form:"key" binding:"required"`
+	Type     models.SpecificationAttributeType `form:"type" binding:"required"`
+	Unit     *string                           `form:"unit"`
+	Position int                               `form:"position" binding:"gte=0"`
+	Values   []string                          `form:"values" binding:"dive,required"`
+}
+
+var specificationAttributeInputFields = map[string]string{"Key": "کلید", "Type": "نوع", "Unit": "واحد", "Position": "ترتیب", "Values": "مقادیر مجاز"}
+
+func (s *SpecificationAttributeHandler) categoryId(c *gin.Context) (uint64, bool) {
+	categoryId, err := strconv.ParseUint(c.Param("categoryId"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه دسته بندی"})
+		return 0, false
+	}
+
+	if _, err := s.categoryService.GetById(categoryId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return 0, false
+	}
+
+	return categoryId, true
+}
+
+func (s *SpecificationAttributeHandler) getAll(c *gin.Context) {
+	categoryId, ok := s.categoryId(c)
+	if !ok {
+		return
+	}
+
+	attributes, err := s.attributeService.GetAll(categoryId)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت ویژگی های دسته بندی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attributes": attributes})
+}
+
+func (s *SpecificationAttributeHandler) create(c *gin.Context) {
+	categoryId, ok := s.categoryId(c)
+	if !ok {
+		return
+	}
+
+	var inputAttribute specificationAttributeInput
+
+	if err := c.ShouldBind(&inputAttribute); err != nil {
+		getErrors := utils.FormValidation(err.Error(), specificationAttributeInputFields)
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	attribute := models.SpecificationAttribute{
+		CategoryID: categoryId,
+		Key:        inputAttribute.Key,
+		Type:       inputAttribute.Type,
+		Unit:       inputAttribute.Unit,
+		Position:   inputAttribute.Position,
+	}
+
+	if err := s.attributeService.Save(&attribute, inputAttribute.Values); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ذخیره ویژگی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "ویژگی با موفقیت ذخیره شد", "attribute": attribute})
+}
+
+func (s *SpecificationAttributeHandler) update(c *gin.Context) {
+	categoryId, ok := s.categoryId(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه ویژگی"})
+		return
+	}
+
+	attribute, err := s.attributeService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if attribute.CategoryID != categoryId {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "این عملیات غیر مجاز است"})
+		return
+	}
+
+	var inputAttribute specificationAttributeInput
+
+	if err := c.ShouldBind(&inputAttribute); err != nil {
+		getErrors := utils.FormValidation(err.Error(), specificationAttributeInputFields)
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	now := time.Now()
+	attribute.Key = inputAttribute.Key
+	attribute.Type = inputAttribute.Type
+	attribute.Unit = inputAttribute.Unit
+	attribute.Position = inputAttribute.Position
+	attribute.ModifiedAt = &now
+
+	if err := s.attributeService.Save(attribute, inputAttribute.Values); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی ویژگی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "ویژگی با موفقیت بروزرسانی شد"})
+}
+
+func (s *SpecificationAttributeHandler) delete(c *gin.Context) {
+	categoryId, ok := s.categoryId(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه ویژگی"})
+		return
+	}
+
+	attribute, err := s.attributeService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if attribute.CategoryID != categoryId {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "این عملیات غیر مجاز است"})
+		return
+	}
+
+	if err := s.attributeService.Delete(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در حذف ویژگی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ویژگی با موفقیت حذف شد"})
+}
+
+func (s *SpecificationAttributeHandler) backfill(c *gin.Context) {
+	categoryId, ok := s.categoryId(c)
+	if !ok {
+		return
+	}
+
+	unmatched, err := s.attributeService.Backfill(categoryId)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در تطبیق مشخصات محصولات با ویژگی ها", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "مشخصات محصولات با ویژگی های دسته بندی تطبیق داده شد", "unmatched": unmatched})
+}
@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SpecificationFilterHandler struct {
+	specificationFilterService *models.SpecificationFilterService
+}
+
+func NewSpecificationFilterHandler(db *gorm.DB) *SpecificationFilterHandler {
+	return &SpecificationFilterHandler{
+		specificationFilterService: models.NewSpecificationFilterService(db),
+	}
+}
+
+type specificationFilterInput struct {
+	Key      string `form:"key" binding:"required"`
+	Position int    `form:"position" binding:"gte=0"`
+}
+
+var specificationFilterInputFields = map[string]string{"Key": "کلید", "Position": "ترتیب"}
+
+func (s *SpecificationFilterHandler) getAll(c *gin.Context) {
+	filters, err := s.specificationFilterService.GetAll()
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت فیلترها", "error": err.Error()})
+		return
+	}
+
+	keys, err := s.specificationFilterService.GetKeys()
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت کلیدهای مشخصات", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"filters": filters, "keys": keys})
+}
+
+func (s *SpecificationFilterHandler) create(c *gin.Context) {
+	var inputFilter specificationFilterInput
+
+	if err := c.ShouldBind(&inputFilter); err != nil {
+		getErrors := utils.FormValidation(err.Error(), specificationFilterInputFields)
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	filter := models.SpecificationFilter{
+		Key:      inputFilter.Key,
+		Position: inputFilter.Position,
+	}
+
+	if err := s.specificationFilterService.Save(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ذخیره فیلتر", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "فیلتر با موفقیت ذخیره شد", "filter": filter})
+}
+
+func (s *SpecificationFilterHandler) update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه فیلتر"})
+		return
+	}
+
+	filter, err := s.specificationFilterService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputFilter specificationFilterInput
+
+	if err := c.ShouldBind(&inputFilter); err != nil {
+		getErrors := utils.FormValidation(err.Error(), specificationFilterInputFields)
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	now := time.Now()
+	filter.Key = inputFilter.Key
+	filter.Position = inputFilter.Position
+	filter.ModifiedAt = &now
+
+	if err := s.specificationFilterService.Save(filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی فیلتر", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "فیلتر با موفقیت بروزرسانی شد"})
+}
+
+func (s *SpecificationFilterHandler) delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه فیلتر"})
+		return
+	}
+
+	if _, err := s.specificationFilterService.GetById(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err := s.specificationFilterService.Delete(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در حذف فیلتر", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "فیلتر با موفقیت حذف شد"})
+}
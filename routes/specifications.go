@@ -14,12 +14,14 @@ import (
 type SpecificationHandler struct {
 	specificationService *models.SpecificationService
 	productService       *models.ProductService
+	attributeService     *models.SpecificationAttributeService
 }
 
 func NewSpecificationHandler(db *gorm.DB) *SpecificationHandler {
 	return &SpecificationHandler{
 		specificationService: models.NewSpecificationService(db),
 		productService:       models.NewProductService(db),
+		attributeService:     models.NewSpecificationAttributeService(db),
 	}
 }
 
@@ -30,7 +32,8 @@ func (s *SpecificationHandler) getAll(c *gin.Context) {
 		return
 	}
 
-	if !s.productService.IsProductById(id) {
+	product, err := s.productService.GetById(id)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "محصول مورد نظر یافت نشد"})
 		return
 	}
@@ -42,7 +45,14 @@ func (s *SpecificationHandler) getAll(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusAccepted, gin.H{"specifications": specifications})
+	attributes, err := s.attributeService.GetAll(product.CategoryID)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت ویژگی های دسته بندی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"specifications": specifications, "attributes": attributes})
 }
 
 func (s *SpecificationHandler) create(c *gin.Context) {
@@ -75,7 +85,7 @@ func (s *SpecificationHandler) create(c *gin.Context) {
 	}
 
 	if err := s.specificationService.Create(specification); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ذخیره مشخصات محصول"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ذخیره مشخصات محصول", "error": err.Error()})
 		return
 	}
 
@@ -132,7 +142,7 @@ func (s *SpecificationHandler) update(c *gin.Context) {
 	specification.ProductID = productId
 
 	if err := s.specificationService.Update(specification); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی مشخصات محصول"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی مشخصات محصول", "error": err.Error()})
 		return
 	}
 
@@ -0,0 +1,129 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TaxClassHandler struct {
+	taxClassService *models.TaxClassService
+}
+
+func NewTaxClassHandler(db *gorm.DB) *TaxClassHandler {
+	return &TaxClassHandler{
+		taxClassService: models.NewTaxClassService(db),
+	}
+}
+
+type taxClassInput struct {
+	Name      string   `form:"name" binding:"required"`
+	Rate      *float64 `form:"rate" binding:"required,gte=0,lte=100"`
+	Inclusive *bool    `form:"inclusive"`
+	IsDefault *bool    `form:"is_default"`
+}
+
+var taxClassInputFields = map[string]string{"Name": "نام", "Rate": "نرخ مالیات", "Inclusive": "مالیات در قیمت", "IsDefault": "پیش فرض"}
+
+func (t *TaxClassHandler) getAll(c *gin.Context) {
+	taxClasses, err := t.taxClassService.GetAll()
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت کلاس های مالیاتی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tax_classes": taxClasses})
+}
+
+func (t *TaxClassHandler) create(c *gin.Context) {
+	var inputTaxClass taxClassInput
+
+	if err := c.ShouldBind(&inputTaxClass); err != nil {
+		getErrors := utils.FormValidation(err.Error(), taxClassInputFields)
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	taxClass := models.TaxClass{
+		Name:      inputTaxClass.Name,
+		Rate:      *inputTaxClass.Rate,
+		Inclusive: inputTaxClass.Inclusive,
+		IsDefault: inputTaxClass.IsDefault,
+	}
+
+	if err := t.taxClassService.Save(&taxClass); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ذخیره کلاس مالیاتی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "کلاس مالیاتی با موفقیت ذخیره شد", "tax_class": taxClass})
+}
+
+func (t *TaxClassHandler) update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه کلاس مالیاتی"})
+		return
+	}
+
+	taxClass, err := t.taxClassService.GetById(id)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var inputTaxClass taxClassInput
+
+	if err := c.ShouldBind(&inputTaxClass); err != nil {
+		getErrors := utils.FormValidation(err.Error(), taxClassInputFields)
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	now := time.Now()
+	taxClass.Name = inputTaxClass.Name
+	taxClass.Rate = *inputTaxClass.Rate
+	if inputTaxClass.Inclusive != nil {
+		taxClass.Inclusive = inputTaxClass.Inclusive
+	}
+	if inputTaxClass.IsDefault != nil {
+		taxClass.IsDefault = inputTaxClass.IsDefault
+	}
+	taxClass.ModifiedAt = &now
+
+	if err := t.taxClassService.Save(taxClass); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در بروزرسانی کلاس مالیاتی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "کلاس مالیاتی با موفقیت بروزرسانی شد"})
+}
+
+func (t *TaxClassHandler) delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه کلاس مالیاتی"})
+		return
+	}
+
+	if _, err := t.taxClassService.GetById(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err := t.taxClassService.Delete(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در حذف کلاس مالیاتی", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "کلاس مالیاتی با موفقیت حذف شد"})
+}
@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TransactionHandler struct {
+	transactionService *models.TransactionService
+}
+
+func NewTransactionHandler(db *gorm.DB) *TransactionHandler {
+	return &TransactionHandler{
+		transactionService: models.NewTransactionService(db),
+	}
+}
+
+func (t *TransactionHandler) getAll(c *gin.Context) {
+	gateway := c.Query("gateway")
+	authority := c.Query("authority")
+	refID := c.Query("refId")
+	status := models.TransactionStatus(c.Query("status"))
+	take := c.Query("take")
+	skip := c.Query("skip")
+	customerId := c.Query("customerId")
+	var customerIdInt uint64
+	if customerId != "" {
+		if parsedId, err := strconv.ParseUint(customerId, 10, 64); err == nil {
+			customerIdInt = parsedId
+		}
+	}
+	takeInt, err := strconv.Atoi(take)
+	if err != nil {
+		takeInt = 10
+	}
+	skipInt, err := strconv.Atoi(skip)
+	if err != nil {
+		skipInt = 0
+	}
+
+	transactions, err := t.transactionService.GetAll(customerIdInt, gateway, authority, refID, status, takeInt, skipInt)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت تراکنش ها", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transactions": transactions})
+}
@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Hello256World/shop-api/models"
+	"github.com/Hello256World/shop-api/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WalletHandler struct {
+	walletService *models.WalletService
+}
+
+func NewWalletHandler(db *gorm.DB) *WalletHandler {
+	return &WalletHandler{
+		walletService: models.NewWalletService(db),
+	}
+}
+
+func (w *WalletHandler) respondWallet(c *gin.Context, customerId uint64) {
+	take := c.Query("take")
+	skip := c.Query("skip")
+	takeInt, err := strconv.Atoi(take)
+	if err != nil {
+		takeInt = 10
+	}
+	skipInt, err := strconv.Atoi(skip)
+	if err != nil {
+		skipInt = 0
+	}
+
+	balance, err := w.walletService.Balance(customerId)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت موجودی کیف پول", "error": err.Error()})
+		return
+	}
+
+	entries, err := w.walletService.GetEntries(customerId, takeInt, skipInt)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در دریافت تراکنش های کیف پول", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"balance": balance, "entries": entries})
+}
+
+func (w *WalletHandler) getByCustomer(c *gin.Context) {
+	w.respondWallet(c, c.GetUint64("customerId"))
+}
+
+func (w *WalletHandler) get(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه مشتری"})
+		return
+	}
+
+	w.respondWallet(c, id)
+}
+
+func (w *WalletHandler) adjust(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در شناسه مشتری"})
+		return
+	}
+
+	var inputAdjustment struct {
+		Amount      *utils.Money           `form:"amount" binding:"required,ne=0"`
+		Kind        models.WalletEntryKind `form:"kind"`
+		Description string                 `form:"description" binding:"required"`
+		OrderID     *uint64                `form:"order_id"`
+	}
+
+	if err := c.ShouldBind(&inputAdjustment); err != nil {
+		getErrors := utils.FormValidation(err.Error(), map[string]string{"Amount": "مبلغ", "Kind": "نوع", "Description": "توضیحات", "OrderID": "شناسه سفارش"})
+		c.JSON(http.StatusBadRequest, gin.H{"message": getErrors})
+		return
+	}
+
+	if inputAdjustment.Kind == "" {
+		inputAdjustment.Kind = models.WalletEntryAdjustment
+	}
+
+	description := models.OrderChangedBy(c.GetString("role"), c.GetUint64("adminId")) + ": " + inputAdjustment.Description
+	if err := w.walletService.Adjust(id, inputAdjustment.Kind, *inputAdjustment.Amount, description, inputAdjustment.OrderID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "خطا در ثبت تراکنش کیف پول", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "کیف پول با موفقیت بروزرسانی شد"})
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// codeAlphabet leaves out characters that are easily mistaken for each
+// other, such as 0 and O or 1 and I.
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// RandomCode returns a random code of groups groups of size characters
+// separated by dashes, e.g. "7KQM-XW2P-D9FA-H3TN" for 4 groups of 4.
+func RandomCode(groups, size int) (string, error) {
+	parts := make([]string, groups)
+	max := big.NewInt(int64(len(codeAlphabet)))
+
+	for i := range parts {
+		var part strings.Builder
+		for j := 0; j < size; j++ {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", err
+			}
+			part.WriteByte(codeAlphabet[n.Int64()])
+		}
+		parts[i] = part.String()
+	}
+
+	return strings.Join(parts, "-"), nil
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// FakeGateway is an in-memory payment gateway for local development.
+// Every payment request is considered paid, and the returned payment URL
+// points straight back to the callback, so a whole checkout can run
+// without network access. Status codes mimic Zarinpal's.
+type FakeGateway struct {
+	mu       sync.Mutex
+	payments map[string]*fakePayment
+	lastRef  int
+}
+
+type fakePayment struct {
+	amount   int
+	status   string
+	refID    string
+	refunded int
+}
+
+var fakeGateway = NewFakeGateway()
+
+// NewFakeGateway creates an empty fake gateway.
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		payments: make(map[string]*fakePayment),
+	}
+}
+
+func (fake *FakeGateway) Name() string {
+	return "fake"
+}
+
+// AmountUnit returns Toman, unlike zarinpal, so unit conversions are
+// exercised when running against the fake gateway.
+func (fake *FakeGateway) AmountUnit() AmountUnit {
+	return AmountUnitToman
+}
+
+func (fake *FakeGateway) NewPaymentRequest(amount int, callbackURL, description, email, mobile string) (paymentURL, authority string, statusCode int, err error) {
+	if amount < 1 {
+		err = errors.New("amount must be a positive number")
+		return
+	}
+	callback, err := url.Parse(callbackURL)
+	if err != nil {
+		return
+	}
+
+	authority = "FAKE-" + uuid.New().String()
+
+	fake.mu.Lock()
+	fake.payments[authority] = &fakePayment{amount: amount, status: "PAID"}
+	fake.mu.Unlock()
+
+	query := callback.Query()
+	query.Set("Authority", authority)
+	query.Set("Status", "OK")
+	callback.RawQuery = query.Encode()
+
+	return callback.String(), authority, 100, nil
+}
+
+// PaymentVerification reports the amount that was actually requested, so a
+// caller passing a different amount sees the mismatch in the result.
+func (fake *FakeGateway) PaymentVerification(amount int, authority string) (result PaymentVerificationResult, err error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	payment, ok := fake.payments[authority]
+	if !ok {
+		result.StatusCode = -51
+		err = errors.New(strconv.Itoa(result.StatusCode))
+		return
+	}
+	if payment.status == "VERIFIED" {
+		result.StatusCode = 101
+		err = errors.New(strconv.Itoa(result.StatusCode))
+		return
+	}
+
+	fake.lastRef++
+	payment.status = "VERIFIED"
+	payment.refID = strconv.Itoa(fake.lastRef)
+
+	cardHash := sha256.Sum256([]byte(authority))
+
+	result.Verified = true
+	result.RefID = payment.refID
+	paid := payment.amount
+	result.Amount = &paid
+	result.StatusCode = 100
+	result.CardPan = "603799******0000"
+	result.CardHash = hex.EncodeToString(cardHash[:])
+	result.Raw = fmt.Sprintf(`{"data":{"code":100,"ref_id":%v,"card_pan":%q}}`, payment.refID, result.CardPan)
+	return
+}
+
+// UnverifiedTransactions lists the payment requests that were never verified.
+func (fake *FakeGateway) UnverifiedTransactions() (authorities []UnverifiedAuthority, statusCode int, err error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	for authority, payment := range fake.payments {
+		if payment.status == "PAID" {
+			authorities = append(authorities, UnverifiedAuthority{
+				Authority: authority,
+				Amount:    payment.amount,
+			})
+		}
+	}
+
+	return authorities, 100, nil
+}
+
+func (fake *FakeGateway) Refund(authority string, amount int) (statusCode int, err error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	payment, ok := fake.payments[authority]
+	if !ok || payment.status != "VERIFIED" {
+		statusCode = -51
+		err = errors.New(strconv.Itoa(statusCode))
+		return
+	}
+	if amount < 1 || payment.refunded+amount > payment.amount {
+		statusCode = -50
+		err = errors.New(strconv.Itoa(statusCode))
+		return
+	}
+
+	payment.refunded += amount
+	if payment.refunded == payment.amount {
+		payment.status = "REVERSED"
+	}
+
+	return 100, nil
+}
+
+// SupportsPartialRefund reports true, payments can be refunded in parts
+// until the whole amount is paid back.
+func (fake *FakeGateway) SupportsPartialRefund() bool {
+	return true
+}
+
+func (fake *FakeGateway) Inquiry(authority string) (status string, statusCode int, err error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	payment, ok := fake.payments[authority]
+	if !ok {
+		statusCode = -51
+		err = errors.New(strconv.Itoa(statusCode))
+		return
+	}
+
+	return payment.status, 100, nil
+}
@@ -40,3 +40,17 @@ func ValidateToken(tokenString string) (map[string]any, error) {
 
 	return nil, errors.New("invalid token")
 }
+
+// CreatePaymentResultToken signs the outcome of a payment so the storefront
+// can show it to the customer without trusting the query string.
+func CreatePaymentResultToken(orderId uint64, succeed bool, refID string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"type":    "payment_result",
+		"orderId": orderId,
+		"succeed": succeed,
+		"refId":   refID,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+
+	return token.SignedString([]byte(secretKey))
+}
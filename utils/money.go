@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MoneyCurrency is the currency every Money is in. Prices are shown in
+// Tomans and kept in Rials, a tenth of a Toman and the smallest unit the
+// shop deals in.
+const MoneyCurrency = AmountUnitToman
+
+// rialsPerToman is the number of minor units in a Toman.
+const rialsPerToman = 10
+
+// Money is an amount of MoneyCurrency counted in its minor unit, so adding
+// up prices never loses a Rial to floating point rounding. It is stored as
+// an integer column and reads and writes as a number of Tomans in JSON and
+// forms, e.g. 125000.5.
+type Money int64
+
+// Toman returns the Money worth toman Tomans, rounded to the nearest Rial.
+func Toman(toman float64) Money {
+	return Money(math.Round(toman * rialsPerToman))
+}
+
+// Rial returns the Money worth rial Rials.
+func Rial(rial int64) Money {
+	return Money(rial)
+}
+
+// ParseMoney reads an amount of Tomans written in decimal, such as "1500" or
+// "1500.5", without going through a float.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+
+	if whole == "" && fraction == "" {
+		return 0, errors.New("مبلغ نامعتبر است")
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if len(strings.TrimRight(fraction, "0")) > 1 {
+		return 0, fmt.Errorf("مبلغ %v بیشتر از یک رقم اعشار دارد", s)
+	}
+
+	tomans, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("مبلغ %v نامعتبر است", s)
+	}
+	var rials int64
+	if fraction != "" {
+		if rials, err = strconv.ParseInt(fraction[:1], 10, 64); err != nil {
+			return 0, fmt.Errorf("مبلغ %v نامعتبر است", s)
+		}
+	}
+
+	money := Money(tomans*rialsPerToman + rials)
+	if negative {
+		money = -money
+	}
+	return money, nil
+}
+
+// Currency returns the currency of m.
+func (m Money) Currency() AmountUnit {
+	return MoneyCurrency
+}
+
+// Rials returns m in Rials.
+func (m Money) Rials() int64 {
+	return int64(m)
+}
+
+// Tomans returns m in Tomans. Use it only where a rate has to be applied.
+func (m Money) Tomans() float64 {
+	return float64(m) / rialsPerToman
+}
+
+// Times returns m multiplied by quantity.
+func (m Money) Times(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Mul returns m multiplied by rate, rounded to the nearest Rial.
+func (m Money) Mul(rate float64) Money {
+	return Money(math.Round(float64(m) * rate))
+}
+
+// Percent returns percent percent of m, rounded to the nearest Rial.
+func (m Money) Percent(percent float64) Money {
+	return m.Mul(percent / 100)
+}
+
+// Share returns the part of m that part is of whole, rounded to the nearest
+// Rial, for spreading an amount over lines in proportion to their totals.
+func (m Money) Share(part, whole Money) Money {
+	if whole == 0 {
+		return 0
+	}
+	return Money(math.Round(float64(m) * float64(part) / float64(whole)))
+}
+
+// RoundToman rounds m to a whole number of Tomans.
+func (m Money) RoundToman() Money {
+	return Toman(math.Round(m.Tomans()))
+}
+
+func (m Money) String() string {
+	sign := ""
+	rials := int64(m)
+	if rials < 0 {
+		sign, rials = "-", -rials
+	}
+	if rials%rialsPerToman == 0 {
+		return fmt.Sprintf("%v%d", sign, rials/rialsPerToman)
+	}
+	return fmt.Sprintf("%v%d.%d", sign, rials/rialsPerToman, rials%rialsPerToman)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts the amount as a JSON number or string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	money, err := ParseMoney(string(bytes.Trim(data, `"`)))
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+// UnmarshalParam reads m from a form or query value.
+func (m *Money) UnmarshalParam(param string) error {
+	money, err := ParseMoney(param)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	valid := map[string]Money{
+		"1500":    15000,
+		"1500.5":  15005,
+		"1500.50": 15005,
+		" 12.0 ":  120,
+		".5":      5,
+		"0":       0,
+		"-2.5":    -25,
+	}
+	for in, want := range valid {
+		if got, err := ParseMoney(in); err != nil || got != want {
+			t.Errorf("ParseMoney(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+
+	// amounts are stored in rials, a fraction of a rial can't be
+	for _, in := range []string{"1500.25", "", "-", "abc", "1.x"} {
+		if got, err := ParseMoney(in); err == nil {
+			t.Errorf("ParseMoney(%q) = %d, want an error", in, got)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	for money, want := range map[Money]string{
+		0:     "0",
+		15000: "1500",
+		15005: "1500.5",
+		5:     "0.5",
+		-25:   "-2.5",
+		-30:   "-3",
+	} {
+		if got := money.String(); got != want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(money), got, want)
+		}
+	}
+}
+
+func TestMoneyRounding(t *testing.T) {
+	check := func(what string, got, want Money) {
+		t.Helper()
+		if got != want {
+			t.Errorf("%v = %d, want %d", what, got, want)
+		}
+	}
+
+	check("Toman(1500.55)", Toman(1500.55), 15006)
+	check("Money(15005).Times(3)", Money(15005).Times(3), 45015)
+	check("Money(15).Mul(0.5)", Money(15).Mul(0.5), 8)
+	check("Money(10000).Percent(9)", Money(10000).Percent(9), 900)
+	check("Money(1005).Percent(9)", Money(1005).Percent(9), 90)
+	check("Money(100).Share(1, 3)", Money(100).Share(1, 3), 33)
+	check("Money(100).Share(2, 3)", Money(100).Share(2, 3), 67)
+	check("Money(100).Share(1, 0)", Money(100).Share(1, 0), 0)
+	check("Money(15004).RoundToman()", Money(15004).RoundToman(), 15000)
+	check("Money(15005).RoundToman()", Money(15005).RoundToman(), 15010)
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var price struct{ Price Money }
+
+	for body, want := range map[string]Money{
+		`{"Price":1500.5}`:   15005,
+		`{"Price":"1500.5"}`: 15005,
+		`{"Price":null}`:     0,
+	} {
+		price.Price = 0
+		if err := json.Unmarshal([]byte(body), &price); err != nil || price.Price != want {
+			t.Errorf("Unmarshal(%v) = %d, %v, want %d", body, price.Price, err, want)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"Price":1500.55}`), &price); err == nil {
+		t.Errorf("Unmarshal of a fraction of a rial = %d, want an error", price.Price)
+	}
+
+	price.Price = 15005
+	if data, err := json.Marshal(price); err != nil || string(data) != `{"Price":1500.5}` {
+		t.Errorf("Marshal = %s, %v", data, err)
+	}
+}
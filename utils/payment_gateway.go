@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+)
+
+// AmountUnit is the currency unit a gateway expects amounts in.
+type AmountUnit string
+
+const (
+	AmountUnitToman AmountUnit = "IRT"
+	AmountUnitRial  AmountUnit = "IRR"
+)
+
+// PaymentGateway is implemented by every payment service provider the shop
+// can send customers to. Amounts are passed in the gateway's AmountUnit and
+// status codes follow the provider's own documentation.
+type PaymentGateway interface {
+	Name() string
+	AmountUnit() AmountUnit
+	NewPaymentRequest(amount int, callbackURL, description, email, mobile string) (paymentURL, authority string, statusCode int, err error)
+	PaymentVerification(amount int, authority string) (result PaymentVerificationResult, err error)
+	Refund(authority string, amount int) (statusCode int, err error)
+	Inquiry(authority string) (status string, statusCode int, err error)
+}
+
+// UnverifiedTransactionsLister is implemented by gateways that can list
+// payments which were paid by the customer but never verified by the shop.
+type UnverifiedTransactionsLister interface {
+	UnverifiedTransactions() (authorities []UnverifiedAuthority, statusCode int, err error)
+}
+
+// PartialRefunder is implemented by gateways that can pay back only part of
+// a payment. Gateways that don't implement it can only reverse a payment as a
+// whole.
+type PartialRefunder interface {
+	SupportsPartialRefund() bool
+}
+
+// PaymentVerificationResult is what a gateway reports back when a payment is
+// verified. Amount and Fee are in the gateway's AmountUnit, Amount being nil
+// when the gateway doesn't report what was paid. CardPan is masked by the
+// gateway and Raw holds the gateway's response as it was received.
+type PaymentVerificationResult struct {
+	Verified   bool
+	RefID      string
+	Amount     *int
+	StatusCode int
+	CardPan    string
+	CardHash   string
+	Fee        int
+	Raw        string
+}
+
+var (
+	_ PaymentGateway = (*Zarinpal)(nil)
+	_ PaymentGateway = (*FakeGateway)(nil)
+
+	_ UnverifiedTransactionsLister = (*Zarinpal)(nil)
+	_ UnverifiedTransactionsLister = (*FakeGateway)(nil)
+
+	_ PartialRefunder = (*FakeGateway)(nil)
+)
+
+// PaymentGatewayFactory builds a ready to use gateway from the environment.
+type PaymentGatewayFactory func() (PaymentGateway, error)
+
+var (
+	paymentGatewaysMu sync.RWMutex
+	paymentGateways   = map[string]PaymentGatewayFactory{
+		"zarinpal": func() (PaymentGateway, error) {
+			zarinpal, err := NewZarinpal(os.Getenv("MERCHANT_ID"), os.Getenv("ZARINPAL_SANDBOX") != "false")
+			if err != nil {
+				return nil, err
+			}
+			return zarinpal, nil
+		},
+		"fake": func() (PaymentGateway, error) {
+			return fakeGateway, nil
+		},
+	}
+)
+
+// RegisterPaymentGateway makes a gateway available under name, so it can be
+// selected with the PAYMENT_GATEWAY environment variable.
+func RegisterPaymentGateway(name string, factory PaymentGatewayFactory) {
+	paymentGatewaysMu.Lock()
+	defer paymentGatewaysMu.Unlock()
+	paymentGateways[name] = factory
+}
+
+// DefaultPaymentGatewayName returns the gateway new orders should be paid with.
+func DefaultPaymentGatewayName() string {
+	if name := os.Getenv("PAYMENT_GATEWAY"); name != "" {
+		return name
+	}
+	return "zarinpal"
+}
+
+// NewPaymentGateway returns the gateway registered under name. An empty name
+// falls back to the default gateway.
+func NewPaymentGateway(name string) (PaymentGateway, error) {
+	if name == "" {
+		name = DefaultPaymentGatewayName()
+	}
+
+	paymentGatewaysMu.RLock()
+	factory, ok := paymentGateways[name]
+	paymentGatewaysMu.RUnlock()
+
+	if !ok {
+		return nil, errors.New("درگاه پرداخت " + name + " تعریف نشده است")
+	}
+
+	return factory()
+}
+
+// ToGatewayAmount converts an amount to the unit the gateway expects. Rials
+// are lost on gateways that take whole Tomans.
+func ToGatewayAmount(gateway PaymentGateway, amount Money) int {
+	if gateway.AmountUnit() == AmountUnitRial {
+		return int(amount.Rials())
+	}
+	return int(amount.RoundToman().Tomans())
+}
+
+// FromGatewayAmount converts an amount reported by the gateway back to Money.
+func FromGatewayAmount(gateway PaymentGateway, amount int) Money {
+	if gateway.AmountUnit() == AmountUnitRial {
+		return Rial(int64(amount))
+	}
+	return Toman(float64(amount))
+}
+
+// PublicURL returns the address customers and gateways can reach path at,
+// based on the PUBLIC_BASE_URL environment variable.
+func PublicURL(path string) string {
+	baseURL := os.Getenv("PUBLIC_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	return strings.TrimRight(baseURL, "/") + path
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// persianLetters maps the Arabic forms of letters and digits, which Arabic
+// keyboards and copied text often use, to the Persian or ASCII form the shop
+// searches in. The zero width non-joiner inside words such as "می‌خواهم" is
+// read as a space.
+var persianLetters = [][2]string{
+	{"ي", "ی"}, {"ى", "ی"}, {"ك", "ک"}, {"ة", "ه"}, {"ۀ", "ه"}, {"\u200c", " "},
+	{"۰", "0"}, {"۱", "1"}, {"۲", "2"}, {"۳", "3"}, {"۴", "4"}, {"۵", "5"}, {"۶", "6"}, {"۷", "7"}, {"۸", "8"}, {"۹", "9"},
+	{"٠", "0"}, {"١", "1"}, {"٢", "2"}, {"٣", "3"}, {"٤", "4"}, {"٥", "5"}, {"٦", "6"}, {"٧", "7"}, {"٨", "8"}, {"٩", "9"},
+}
+
+// persianMarks are dropped altogether: the tatweel and the short vowel marks.
+const persianMarks = "\u0640\u064b\u064c\u064d\u064e\u064f\u0650\u0651\u0652"
+
+var persianReplacer = func() *strings.Replacer {
+	var pairs []string
+	for _, letter := range persianLetters {
+		pairs = append(pairs, letter[0], letter[1])
+	}
+	for _, mark := range persianMarks {
+		pairs = append(pairs, string(mark), "")
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// NormalizePersian rewrites s with the letters and digits of persianLetters,
+// so "كيف ۸" and "کیف 8" read the same.
+func NormalizePersian(s string) string {
+	return persianReplacer.Replace(s)
+}
+
+// NormalizePersianSQL returns the SQL expression doing what NormalizePersian
+// does to the text of expr.
+func NormalizePersianSQL(expr string) string {
+	var from, to strings.Builder
+	for _, letter := range persianLetters {
+		from.WriteString(letter[0])
+		to.WriteString(letter[1])
+	}
+	// translate drops the characters of from that have nothing in to
+	from.WriteString(persianMarks)
+	return fmt.Sprintf("translate(%v, '%v', '%v')", expr, from.String(), to.String())
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNormalizePersian(t *testing.T) {
+	for rule, cases := range map[string][][2]string{
+		"arabic letters": {{"كيف", "کیف"}, {"علي", "علی"}, {"موسى", "موسی"}, {"مدرسة", "مدرسه"}, {"خانۀ", "خانه"}},
+		"digits":         {{"كيف ۸", "کیف 8"}, {"١٢٣ ۴۵۶", "123 456"}},
+		"half space":     {{"می\u200cخواهم", "می خواهم"}},
+		"kashida":        {{"كـــتاب", "کتاب"}},
+		"diacritics":     {{"مُحَمَّد", "محمد"}},
+		"already normal": {{"کیف 8", "کیف 8"}, {"RAM 16GB", "RAM 16GB"}, {"", ""}},
+	} {
+		for _, c := range cases {
+			if got := NormalizePersian(c[0]); got != c[1] {
+				t.Errorf("%v: NormalizePersian(%q) = %q, want %q", rule, c[0], got, c[1])
+			}
+		}
+	}
+}
+
+// translate maps the characters of its second argument to those of the third
+// by position, so the two must line up for the SQL to match NormalizePersian.
+func TestNormalizePersianSQL(t *testing.T) {
+	sql := NormalizePersianSQL("name")
+
+	args := strings.Split(strings.TrimSuffix(strings.TrimPrefix(sql, "translate(name, '"), "')"), "', '")
+	if len(args) != 2 {
+		t.Fatalf("NormalizePersianSQL = %q, want translate(name, from, to)", sql)
+	}
+	from, to := []rune(args[0]), []rune(args[1])
+	if len(from) != len(to)+utf8.RuneCountInString(persianMarks) {
+		t.Fatalf("translate has %v characters to replace for %v replacements and %v marks", len(from), len(to), utf8.RuneCountInString(persianMarks))
+	}
+	for i, replacement := range to {
+		if got := NormalizePersian(string(from[i])); got != string(replacement) {
+			t.Errorf("translate maps %q to %q, NormalizePersian to %q", from[i], replacement, got)
+		}
+	}
+	for _, mark := range from[len(to):] {
+		if got := NormalizePersian(string(mark)); got != "" {
+			t.Errorf("translate drops %q, NormalizePersian turns it into %q", mark, got)
+		}
+	}
+}
@@ -57,24 +57,28 @@ type paymentVerificationResp struct {
 }
 
 type unverifiedTransactionsReqBody struct {
-	MerchantID string
+	MerchantID string `json:"merchant_id"`
 }
 
 // UnverifiedAuthority is the base struct for Authorities in unverifiedTransactionsResp
 type UnverifiedAuthority struct {
-	Authority   string
-	Amount      int
-	Channel     string
-	CallbackURL string
-	Referer     string
-	Email       string
-	CellPhone   string
-	Date        string // ToDo Check type to be date
+	Authority   string `json:"authority"`
+	Amount      int    `json:"amount"`
+	Channel     string `json:"channel"`
+	CallbackURL string `json:"callback_url"`
+	Referer     string `json:"referer"`
+	Email       string `json:"email"`
+	CellPhone   string `json:"mobile"`
+	Date        string `json:"date"` // ToDo Check type to be date
 }
 
 type unverifiedTransactionsResp struct {
-	Status      int
-	Authorities []UnverifiedAuthority
+	Data struct {
+		Status      int                   `json:"code"`
+		Message     string                `json:"message"`
+		Authorities []UnverifiedAuthority `json:"authorities"`
+	} `json:"data"`
+	Errors []any
 }
 
 type refreshAuthorityReqBody struct {
@@ -87,6 +91,33 @@ type refreshAuthorityResp struct {
 	Status int
 }
 
+type reverseReqBody struct {
+	MerchantID string `json:"merchant_id"`
+	Authority  string `json:"authority"`
+}
+
+type reverseResp struct {
+	Data struct {
+		StatusCode int    `json:"code"`
+		Message    string `json:"message"`
+	} `json:"data"`
+	Errors []any
+}
+
+type inquiryReqBody struct {
+	MerchantID string `json:"merchant_id"`
+	Authority  string `json:"authority"`
+}
+
+type inquiryResp struct {
+	Data struct {
+		StatusCode int    `json:"code"`
+		Message    string `json:"message"`
+		Status     string `json:"status"`
+	} `json:"data"`
+	Errors []any
+}
+
 // NewZarinpal creates a new instance of zarinpal payment
 // gateway with provided configs. It also tries to validate
 // provided configs.
@@ -108,8 +139,19 @@ func NewZarinpal(merchantID string, sandbox bool) (*Zarinpal, error) {
 	}, nil
 }
 
+// Name returns the name zarinpal is registered with as a PaymentGateway.
+func (zarinpal *Zarinpal) Name() string {
+	return "zarinpal"
+}
+
+// AmountUnit returns the unit zarinpal amounts are sent in. Version 4 of
+// the API works in Rials unless a currency is passed explicitly.
+func (zarinpal *Zarinpal) AmountUnit() AmountUnit {
+	return AmountUnitRial
+}
+
 // NewPaymentRequest gets a payment url from Zarinpal.
-// amount is in Tomans (not Rials) format.
+// amount is in Rials (not Tomans) format.
 // email and mobile are optional.
 //
 // If error is not nil, you can check statusCode for
@@ -155,7 +197,10 @@ func (zarinpal *Zarinpal) NewPaymentRequest(amount int, callbackURL, description
 }
 
 // PaymentVerification verifies if a payment was done successfully, Authority of the
-// payment request should be passed to this method alongside its Amount in Tomans.
+// payment request should be passed to this method alongside its Amount in Rials.
+// Zarinpal doesn't report the amount that was paid, it refuses to verify a payment
+// with an amount other than the one that was requested instead, so the Amount of
+// the result is always nil.
 //
 // If error is not nil, you can check statusCode for
 // specific error handling based on Zarinpal error codes.
@@ -163,7 +208,7 @@ func (zarinpal *Zarinpal) NewPaymentRequest(amount int, callbackURL, description
 // on their end and you can check the error code and its reason
 // based on their documentation placed in
 // https://github.com/ZarinPal-Lab/Documentation-PaymentGateway/archive/master.zip
-func (zarinpal *Zarinpal) PaymentVerification(amount int, authority string) (verified bool, refID string, statusCode int, err error) {
+func (zarinpal *Zarinpal) PaymentVerification(amount int, authority string) (result PaymentVerificationResult, err error) {
 	if amount <= 0 {
 		err = errors.New("amount must be a positive number")
 		return
@@ -177,22 +222,31 @@ func (zarinpal *Zarinpal) PaymentVerification(amount int, authority string) (ver
 		Amount:     amount,
 		Authority:  authority,
 	}
-	var resp paymentVerificationResp
-	err = zarinpal.request("verify.json", &paymentVerification, &resp)
+	body, err := zarinpal.requestRaw("verify.json", &paymentVerification)
 	if err != nil {
 		return
 	}
-	statusCode = resp.Data.StatusCode
+	result.Raw = string(body)
+	var resp paymentVerificationResp
+	if err = json.Unmarshal(body, &resp); err != nil {
+		err = errors.New("zarinpal invalid json response")
+		return
+	}
+	result.StatusCode = resp.Data.StatusCode
 	if resp.Data.StatusCode == 100 {
-		verified = true
-		refID = string(resp.Data.RefID)
+		result.Verified = true
+		result.RefID = strconv.Itoa(resp.Data.RefID)
+		result.CardPan = resp.Data.CardPan
+		result.CardHash = resp.Data.CardHash
+		result.Fee = resp.Data.Fee
 	} else {
 		err = errors.New(strconv.Itoa(resp.Data.StatusCode))
 	}
 	return
 }
 
-// UnverifiedTransactions gets unverified transactions.
+// UnverifiedTransactions gets the paid payments of the last hours that were
+// never verified. Amounts are in Rials.
 //
 // If error is not nil, you can check statusCode for
 // specific error handling based on Zarinpal error codes.
@@ -206,16 +260,16 @@ func (zarinpal *Zarinpal) UnverifiedTransactions() (authorities []UnverifiedAuth
 	}
 
 	var resp unverifiedTransactionsResp
-	err = zarinpal.request("UnverifiedTransactions.json", &unverifiedTransactions, &resp)
+	err = zarinpal.request("unVerified.json", &unverifiedTransactions, &resp)
 	if err != nil {
 		return
 	}
 
-	if resp.Status == 100 {
-		statusCode = resp.Status
-		authorities = resp.Authorities
+	statusCode = resp.Data.Status
+	if resp.Data.Status == 100 {
+		authorities = resp.Data.Authorities
 	} else {
-		err = errors.New(strconv.Itoa(resp.Status))
+		err = errors.New(strconv.Itoa(resp.Data.Status))
 	}
 	return
 }
@@ -260,32 +314,108 @@ func (zarinpal *Zarinpal) RefreshAuthority(authority string, expire int) (status
 	return
 }
 
+// Refund reverses a verified payment. Zarinpal only supports reversing the
+// whole amount of a payment, so amount must be equal to the paid amount.
+//
+// If error is not nil, you can check statusCode for
+// specific error handling based on Zarinpal error codes.
+// If statusCode is not 100, it means Zarinpal raised an error
+// on their end and you can check the error code and its reason
+// based on their documentation placed in
+// https://github.com/ZarinPal-Lab/Documentation-PaymentGateway/archive/master.zip
+func (zarinpal *Zarinpal) Refund(authority string, amount int) (statusCode int, err error) {
+	if authority == "" {
+		err = errors.New("authority should not be empty")
+		return
+	}
+	if amount <= 0 {
+		err = errors.New("amount must be a positive number")
+		return
+	}
+	reverse := reverseReqBody{
+		MerchantID: zarinpal.MerchantID,
+		Authority:  authority,
+	}
+	var resp reverseResp
+	err = zarinpal.request("reverse.json", &reverse, &resp)
+	if err != nil {
+		return
+	}
+	statusCode = resp.Data.StatusCode
+	if resp.Data.StatusCode != 100 {
+		err = errors.New(strconv.Itoa(resp.Data.StatusCode))
+	}
+	return
+}
+
+// Inquiry gets the current status of a payment, e.g. PAID, VERIFIED,
+// IN_BANK, FAILED or REVERSED.
+//
+// If error is not nil, you can check statusCode for
+// specific error handling based on Zarinpal error codes.
+// If statusCode is not 100, it means Zarinpal raised an error
+// on their end and you can check the error code and its reason
+// based on their documentation placed in
+// https://github.com/ZarinPal-Lab/Documentation-PaymentGateway/archive/master.zip
+func (zarinpal *Zarinpal) Inquiry(authority string) (status string, statusCode int, err error) {
+	if authority == "" {
+		err = errors.New("authority should not be empty")
+		return
+	}
+	inquiry := inquiryReqBody{
+		MerchantID: zarinpal.MerchantID,
+		Authority:  authority,
+	}
+	var resp inquiryResp
+	err = zarinpal.request("inquiry.json", &inquiry, &resp)
+	if err != nil {
+		return
+	}
+	statusCode = resp.Data.StatusCode
+	if resp.Data.StatusCode == 100 {
+		status = resp.Data.Status
+	} else {
+		err = errors.New(strconv.Itoa(resp.Data.StatusCode))
+	}
+	return
+}
+
 func (zarinpal *Zarinpal) request(method string, data interface{}, res interface{}) error {
-	reqBytes, err := json.Marshal(data)
+	body, err := zarinpal.requestRaw(method, data)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest("POST", zarinpal.APIEndpoint+method, bytes.NewBuffer(reqBytes))
+	err = json.Unmarshal(body, res)
 	if err != nil {
+		err = errors.New("zarinpal invalid json response")
 		return err
 	}
+	return nil
+}
+
+// requestRaw posts data to the given API method and returns the response body
+// untouched, for callers that need to keep zarinpal's own response around.
+func (zarinpal *Zarinpal) requestRaw(method string, data interface{}) ([]byte, error) {
+	reqBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", zarinpal.APIEndpoint+method, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	log.Println(string(body))
-	err = json.Unmarshal(body, res)
-	if err != nil {
-		err = errors.New("zarinpal invalid json response")
-		return err
-	}
-	return nil
+	return body, nil
 }